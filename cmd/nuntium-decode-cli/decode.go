@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -34,18 +35,46 @@ func main() {
 	retConfHdr := mms.NewMRetrieveConf(mmsFile)
 	dec := mms.NewDecoder(mmsData)
 	if err := dec.Decode(retConfHdr); err != nil {
+		fmt.Println(dec.GetLog())
 		fmt.Println(err)
+		if decodeErr, ok := err.(*mms.DecodeError); ok {
+			dumpOffset(mmsData, decodeErr.Offset)
+		}
 		os.Exit(1)
 	}
 
+	for _, header := range retConfHdr.UnknownHeaders {
+		if header.Name != "" {
+			fmt.Printf("Header %s: %s\n", header.Name, header.Value)
+		} else {
+			fmt.Printf("Header %#x: %x\n", header.Code, header.Value)
+		}
+	}
+
 	if targetPath != "" {
 		fmt.Println("Saving to", targetPath)
-		writeParts(targetPath, retConfHdr.Attachments)
+		writeParts(targetPath, mms.FlattenAttachments(retConfHdr.Attachments))
 	}
 
 	fmt.Println(dec.GetLog())
 }
 
+//dumpOffset prints the data surrounding the offset where decoding failed
+func dumpOffset(data []byte, offset int) {
+	start := offset - 16
+	if start < 0 {
+		start = 0
+	}
+	end := offset + 16
+	if end > len(data) {
+		end = len(data)
+	}
+	if start >= end {
+		return
+	}
+	fmt.Printf("Data from offset %d:\n%s", start, hex.Dump(data[start:end]))
+}
+
 func usage() {
 	fmt.Printf("Usage: %s [mms] [decode dir]\n", os.Args[0])
 	os.Exit(1)
@@ -44,15 +44,28 @@ type Mediator struct {
 	NewMSendReq         chan *mms.MSendReq
 	NewMSendReqFile     chan struct{ filePath, uuid string }
 	outMessage          chan *telepathy.OutgoingMessage
+	readMessage         chan string
+	retrieveMessage     chan string
+	forwardMessage      chan *telepathy.ForwardMessage
 	terminate           chan bool
 	contextLock         sync.Mutex
+	deferredLock        sync.Mutex
+	deferred            map[string]*mms.MNotificationInd
+	retrieving          map[string]bool
+	downloadsLock       sync.Mutex
+	downloads           map[string]*download
+}
+
+//download is a message being downloaded, closing cancel aborts it.
+type download struct {
+	mNotificationInd *mms.MNotificationInd
+	cancel           chan struct{}
 }
 
 //TODO these vars need a configuration location managed by system settings or
 //some UI accessible location.
 //useDeliveryReports is set in ofono
 var (
-	deferredDownload   bool
 	useDeliveryReports bool
 )
 
@@ -62,6 +75,12 @@ func NewMediator(modem *ofono.Modem) *Mediator {
 	mediator.NewMSendReq = make(chan *mms.MSendReq)
 	mediator.NewMSendReqFile = make(chan struct{ filePath, uuid string })
 	mediator.outMessage = make(chan *telepathy.OutgoingMessage)
+	mediator.readMessage = make(chan string)
+	mediator.retrieveMessage = make(chan string)
+	mediator.forwardMessage = make(chan *telepathy.ForwardMessage)
+	mediator.deferred = make(map[string]*mms.MNotificationInd)
+	mediator.retrieving = make(map[string]bool)
+	mediator.downloads = make(map[string]*download)
 	mediator.terminate = make(chan bool)
 	return mediator
 }
@@ -82,25 +101,35 @@ mediatorLoop:
 			if !mmsEnabled() {
 				continue
 			}
-			go mediator.handleMNotificationInd(push)
+			go mediator.handlePush(push)
 		case mNotificationInd := <-mediator.NewMNotificationInd:
-			if deferredDownload {
+			// messages for a registered application are not left for the
+			// user to download
+			if mediator.telepathyService != nil && mediator.telepathyService.IsDeferredDownload() &&
+				!mediator.telepathyService.IsApplicationRegistered(mNotificationInd.ApplicId) {
 				go mediator.handleDeferredDownload(mNotificationInd)
 			} else {
-				go mediator.getMRetrieveConf(mNotificationInd)
+				go mediator.getMRetrieveConf(mNotificationInd, false)
 			}
 		case msg := <-mediator.outMessage:
 			go mediator.handleOutgoingMessage(msg)
+		case uuid := <-mediator.readMessage:
+			go mediator.handleMarkRead(uuid)
+		case uuid := <-mediator.retrieveMessage:
+			go mediator.handleRetrieve(uuid)
+		case msg := <-mediator.forwardMessage:
+			go mediator.handleForward(msg)
 		case mSendReq := <-mediator.NewMSendReq:
 			go mediator.handleMSendReq(mSendReq)
 		case mSendReqFile := <-mediator.NewMSendReqFile:
-			go mediator.sendMSendReq(mSendReqFile.filePath, mSendReqFile.uuid)
+			go mediator.sendMSendReq(mSendReqFile.filePath, mSendReqFile.uuid, false)
 		case id := <-mediator.modem.IdentityAdded:
 			var err error
-			mediator.telepathyService, err = mmsManager.AddService(id, mediator.modem.Modem, mediator.outMessage, useDeliveryReports)
+			mediator.telepathyService, err = mmsManager.AddService(id, mediator.modem.Modem, mediator.outMessage, mediator.readMessage, mediator.retrieveMessage, mediator.forwardMessage, useDeliveryReports)
 			if err != nil {
 				log.Fatal(err)
 			}
+			go mediator.restorePending(mediator.telepathyService)
 		case id := <-mediator.modem.IdentityRemoved:
 			err := mmsManager.RemoveService(id)
 			if err != nil {
@@ -135,29 +164,273 @@ mediatorLoop:
 	log.Print("Ending mediator instance loop for modem")
 }
 
-func (mediator *Mediator) handleMNotificationInd(pushMsg *ofono.PushPDU) {
+//handlePush decodes the MMS PDU carried by the push and routes it to the
+//handler for its message type.
+func (mediator *Mediator) handlePush(pushMsg *ofono.PushPDU) {
 	if pushMsg == nil {
 		log.Print("Received nil push")
 		return
 	}
-	dec := mms.NewDecoder(pushMsg.Data)
-	mNotificationInd := mms.NewMNotificationInd()
-	if err := dec.Decode(mNotificationInd); err != nil {
-		log.Println("Unable to decode m-notification.ind: ", err, "with log", dec.GetLog())
+	dec := mms.NewDecoder(pushMsg.Data, mms.DefaultDecoderLimits)
+	pdu, err := dec.DecodeAny()
+	if err != nil {
+		log.Println("Unable to decode push: ", err, "with log", dec.GetLog())
 		return
 	}
+	switch pdu := pdu.(type) {
+	case *mms.MNotificationInd:
+		mediator.handleMNotificationInd(pdu)
+	case *mms.MDeliveryInd:
+		mediator.handleMDeliveryInd(pdu)
+	case *mms.MReadOrigInd:
+		mediator.handleMReadOrigInd(pdu)
+	case *mms.MCancelReq:
+		mediator.handleMCancelReq(pdu)
+	default:
+		log.Printf("Unhandled push for %T", pdu)
+	}
+}
+
+func (mediator *Mediator) handleMNotificationInd(mNotificationInd *mms.MNotificationInd) {
 	storage.Create(mNotificationInd.UUID, mNotificationInd.ContentLocation)
 	mediator.NewMNotificationInd <- mNotificationInd
 }
 
+func (mediator *Mediator) handleMDeliveryInd(mDeliveryInd *mms.MDeliveryInd) {
+	var recipient string
+	if len(mDeliveryInd.To) > 0 {
+		recipient = mDeliveryInd.To[0]
+	}
+	sendState, status := deliveryStatus(mDeliveryInd.Status)
+	uuid, err := storage.UpdateSendState(mDeliveryInd.MessageId, recipient, sendState)
+	if err != nil {
+		log.Println("Cannot match m-delivery.ind to a sent message:", err)
+		return
+	}
+	log.Printf("Delivery report for %s to %s: %s", uuid, recipient, sendState)
+	if status == "" {
+		return
+	}
+	if mediator.telepathyService == nil {
+		log.Print("Not sending delivery report status")
+		return
+	}
+	if err := mediator.telepathyService.MessageStatusChanged(uuid, status); err != nil {
+		log.Println(err)
+	}
+}
+
+func (mediator *Mediator) handleMReadOrigInd(mReadOrigInd *mms.MReadOrigInd) {
+	sendState := storage.READ
+	if mReadOrigInd.ReadStatus == mms.ReadStatusDeleted {
+		sendState = storage.DELETED
+	}
+	uuid, err := storage.UpdateSendState(mReadOrigInd.MessageId, mReadOrigInd.From, sendState)
+	if err != nil {
+		log.Println("Cannot match m-read-orig.ind to a sent message:", err)
+		return
+	}
+	log.Printf("Read report for %s from %s: %s", uuid, mReadOrigInd.From, sendState)
+	if sendState != storage.READ {
+		return
+	}
+	if mediator.telepathyService == nil {
+		log.Print("Not sending read report status")
+		return
+	}
+	if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.READ); err != nil {
+		log.Println(err)
+	}
+}
+
+//handleMCancelReq aborts the deferred or ongoing download of the message
+//mCancelReq refers to, removes it and confirms the cancellation to the
+//message center with a m-cancel.conf.
+func (mediator *Mediator) handleMCancelReq(mCancelReq *mms.MCancelReq) {
+	var cancelled *mms.MNotificationInd
+	mediator.deferredLock.Lock()
+	for uuid, mNotificationInd := range mediator.deferred {
+		if mCancelReq.Cancels(mNotificationInd) {
+			cancelled = mNotificationInd
+			delete(mediator.deferred, uuid)
+			break
+		}
+	}
+	mediator.deferredLock.Unlock()
+
+	mediator.downloadsLock.Lock()
+	for uuid, d := range mediator.downloads {
+		if mCancelReq.Cancels(d.mNotificationInd) {
+			cancelled = d.mNotificationInd
+			close(d.cancel)
+			delete(mediator.downloads, uuid)
+			break
+		}
+	}
+	mediator.downloadsLock.Unlock()
+
+	status := mms.CancelStatusReceived
+	if mCancelReq.CancelId == "" {
+		status = mms.CancelStatusCorrupted
+	}
+	if cancelled == nil {
+		log.Print("No pending download matches the m-cancel.req for ", mCancelReq.CancelId)
+	} else {
+		log.Print("Message center cancelled the download of ", cancelled.UUID)
+		if mediator.telepathyService != nil {
+			if err := mediator.telepathyService.MessageCancelled(cancelled.UUID); err != nil {
+				log.Println("Cannot remove cancelled message:", err)
+			}
+		} else if err := storage.Destroy(cancelled.UUID); err != nil {
+			log.Println("Cannot remove cancelled message:", err)
+		}
+		if cancelled.IsLocal() {
+			log.Print("This is a local test, skipping m-cancel.conf")
+			return
+		}
+	}
+
+	if mediator.telepathyService == nil {
+		log.Print("Not sending m-cancel.conf without a service")
+		return
+	}
+	mCancelConf := mCancelReq.NewMCancelConf(status)
+	if filePath := mediator.handleMCancelConf(mCancelConf); filePath != "" {
+		defer os.Remove(filePath)
+		if responseFile, err := mediator.uploadFile(filePath); err != nil {
+			log.Printf("Cannot upload m-cancel.conf encoded file %s to message center: %s", filePath, err)
+		} else {
+			os.Remove(responseFile)
+		}
+	}
+}
+
+//deliveryStatus maps an X-Mms-Status value to the state stored for the
+//recipient and to the telepathy status for the message, the latter is empty
+//for reports that are not final.
+func deliveryStatus(status byte) (sendState, telepathyStatus string) {
+	switch status {
+	case mms.STATUS_RETRIEVED:
+		return storage.RETRIEVED, telepathy.DELIVERED
+	case mms.STATUS_FORWARDED:
+		return storage.FORWARDED, telepathy.DELIVERED
+	case mms.STATUS_REJECTED:
+		return storage.REJECTED, telepathy.REJECTED
+	case mms.STATUS_EXPIRED:
+		return storage.EXPIRED, telepathy.EXPIRED
+	case mms.STATUS_DEFERRED:
+		return storage.DEFERRED, ""
+	case mms.STATUS_UNREACHABLE:
+		return storage.UNREACHABLE, ""
+	}
+	return storage.INDETERMINATE, ""
+}
+
+//handleDeferredDownload tells the message center that the message will be
+//retrieved later on and lets the client decide when to download it.
 func (mediator *Mediator) handleDeferredDownload(mNotificationInd *mms.MNotificationInd) {
-	//TODO send MessageAdded with status="deferred" and mNotificationInd relevant headers
+	mediator.deferredLock.Lock()
+	mediator.deferred[mNotificationInd.UUID] = mNotificationInd
+	mediator.deferredLock.Unlock()
+	if err := storage.UpdatePending(mNotificationInd); err != nil {
+		log.Print("Cannot store deferred message ", mNotificationInd.UUID, ": ", err)
+	}
+
+	if !mNotificationInd.IsLocal() {
+		mNotifyRespInd := mNotificationInd.NewMNotifyRespInd(mms.STATUS_DEFERRED, useDeliveryReports)
+		if filePath := mediator.handleMNotifyRespInd(mNotifyRespInd); filePath != "" {
+			defer os.Remove(filePath)
+			if responseFile, err := mediator.uploadFile(filePath); err != nil {
+				log.Printf("Cannot upload m-notifyresp.ind encoded file %s to message center: %s", filePath, err)
+			} else {
+				os.Remove(responseFile)
+			}
+		}
+	} else {
+		log.Print("This is a local test, skipping m-notifyresp.ind")
+	}
+
+	if mediator.telepathyService == nil {
+		log.Print("Not announcing deferred message ", mNotificationInd.UUID)
+		return
+	}
+	if err := mediator.telepathyService.DeferredMessageAdded(mNotificationInd); err != nil {
+		log.Println("Cannot notify deferred message:", err)
+	}
 }
 
-func (mediator *Mediator) getMRetrieveConf(mNotificationInd *mms.MNotificationInd) {
+//handleRetrieve downloads a message that was previously deferred, it is kept
+//pending until the download succeeds so that retrieval can be retried but
+//calls made while it is being retrieved are ignored.
+func (mediator *Mediator) handleRetrieve(uuid string) {
+	mediator.deferredLock.Lock()
+	if mediator.retrieving[uuid] {
+		mediator.deferredLock.Unlock()
+		log.Print("Deferred message ", uuid, " is already being retrieved")
+		return
+	}
+	mNotificationInd, ok := mediator.deferred[uuid]
+	if !ok {
+		// the message may have been deferred before a restart
+		if pending, err := storage.GetPending(uuid); err == nil {
+			mNotificationInd, ok = pending, true
+			mediator.deferred[uuid] = mNotificationInd
+		}
+	}
+	if ok {
+		mediator.retrieving[uuid] = true
+	}
+	mediator.deferredLock.Unlock()
+
+	if !ok {
+		log.Print("No deferred message pending retrieval for ", uuid)
+		return
+	}
+	mediator.getMRetrieveConf(mNotificationInd, true)
+
+	mediator.deferredLock.Lock()
+	delete(mediator.retrieving, uuid)
+	mediator.deferredLock.Unlock()
+}
+
+//restorePending tracks the messages that were deferred before nuntium
+//restarted and announces them again on service so they can be retrieved.
+func (mediator *Mediator) restorePending(service *telepathy.MMSService) {
+	pending, err := storage.ListPending()
+	if err != nil {
+		log.Print("Cannot list deferred messages: ", err)
+		return
+	}
+	for _, mNotificationInd := range pending {
+		mediator.deferredLock.Lock()
+		mediator.deferred[mNotificationInd.UUID] = mNotificationInd
+		mediator.deferredLock.Unlock()
+		if service == nil {
+			continue
+		}
+		if err := service.DeferredMessageAdded(mNotificationInd); err != nil {
+			log.Println("Cannot notify deferred message:", err)
+		}
+	}
+}
+
+//getMRetrieveConf downloads the message for mNotificationInd and acknowledges
+//it, deferred tells if the notification was previously answered with a
+//deferred status in which case a m-acknowledge.ind is used as the response.
+func (mediator *Mediator) getMRetrieveConf(mNotificationInd *mms.MNotificationInd, deferred bool) {
+	cancel := mediator.startDownload(mNotificationInd)
+	defer mediator.endDownload(mNotificationInd.UUID)
+
 	mediator.contextLock.Lock()
 	defer mediator.contextLock.Unlock()
 
+	select {
+	case <-cancel:
+		log.Print("Download of ", mNotificationInd.UUID, " cancelled before it started")
+		return
+	default:
+	}
+
 	var proxy ofono.ProxyInfo
 	var mmsContext ofono.OfonoContext
 
@@ -187,15 +460,22 @@ func (mediator *Mediator) getMRetrieveConf(mNotificationInd *mms.MNotificationIn
 		}
 	}
 
-	if filePath, err := mNotificationInd.DownloadContent(proxy.Host, int32(proxy.Port)); err != nil {
+	filePath, err := mNotificationInd.DownloadContent(proxy.Host, int32(proxy.Port), cancel)
+	if !mediator.endDownload(mNotificationInd.UUID) {
+		log.Print("Download of ", mNotificationInd.UUID, " cancelled by the message center")
+		if err == nil {
+			os.Remove(filePath)
+		}
+		return
+	}
+	if err != nil {
 		//TODO telepathy service signal the download error
 		log.Print("Download issues: ", err)
 		return
-	} else {
-		if err := storage.UpdateDownloaded(mNotificationInd.UUID, filePath); err != nil {
-			log.Println("When calling UpdateDownloaded: ", err)
-			return
-		}
+	}
+	if err := storage.UpdateDownloaded(mNotificationInd.UUID, filePath); err != nil {
+		log.Println("When calling UpdateDownloaded: ", err)
+		return
 	}
 
 	mRetrieveConf, err := mediator.handleMRetrieveConf(mNotificationInd.UUID)
@@ -204,25 +484,77 @@ func (mediator *Mediator) getMRetrieveConf(mNotificationInd *mms.MNotificationIn
 		return
 	}
 
-	mNotifyRespInd := mRetrieveConf.NewMNotifyRespInd(useDeliveryReports)
-	if err := storage.UpdateRetrieved(mNotifyRespInd.UUID); err != nil {
+	if err := storage.UpdateRetrieved(mRetrieveConf.UUID); err != nil {
 		log.Print("Can't update mms status: ", err)
 		return
 	}
+	if deferred {
+		mediator.deferredLock.Lock()
+		delete(mediator.deferred, mNotificationInd.UUID)
+		mediator.deferredLock.Unlock()
+	}
 
 	if !mNotificationInd.IsLocal() {
-		// TODO deferred case
-		filePath := mediator.handleMNotifyRespInd(mNotifyRespInd)
+		var filePath string
+		if deferred {
+			filePath = mediator.handleMAcknowledgeInd(mRetrieveConf.NewMAcknowledgeInd(useDeliveryReports))
+		} else {
+			filePath = mediator.handleMNotifyRespInd(mRetrieveConf.NewMNotifyRespInd(useDeliveryReports))
+		}
 		if filePath == "" {
 			return
 		}
-		mediator.sendMNotifyRespInd(filePath, &mmsContext)
+		mediator.sendResponse(filePath, &mmsContext)
 	} else {
-		log.Print("This is a local test, skipping m-notifyresp.ind")
+		log.Print("This is a local test, skipping the retrieval response")
 	}
 }
 
+//startDownload tracks the download of mNotificationInd so that the message
+//center can cancel it, the returned channel is closed if it does.
+func (mediator *Mediator) startDownload(mNotificationInd *mms.MNotificationInd) <-chan struct{} {
+	cancel := make(chan struct{})
+	mediator.downloadsLock.Lock()
+	mediator.downloads[mNotificationInd.UUID] = &download{mNotificationInd: mNotificationInd, cancel: cancel}
+	mediator.downloadsLock.Unlock()
+	return cancel
+}
+
+//endDownload stops tracking the download of uuid, it returns false if the
+//download was cancelled.
+func (mediator *Mediator) endDownload(uuid string) bool {
+	mediator.downloadsLock.Lock()
+	defer mediator.downloadsLock.Unlock()
+	_, ok := mediator.downloads[uuid]
+	delete(mediator.downloads, uuid)
+	return ok
+}
+
 func (mediator *Mediator) handleMRetrieveConf(uuid string) (*mms.MRetrieveConf, error) {
+	mRetrieveConf, err := loadMRetrieveConf(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if mediator.telepathyService == nil {
+		log.Print("Not sending recently retrieved message")
+		return mRetrieveConf, nil
+	}
+	if delivered, err := mediator.telepathyService.ApplicationMessageAdded(mRetrieveConf); err != nil {
+		log.Println("Cannot deliver message to the application registered for", mRetrieveConf.ApplicId, err)
+	} else if delivered {
+		log.Printf("Delivered %s to the application registered for %s", mRetrieveConf.UUID, mRetrieveConf.ApplicId)
+		return mRetrieveConf, nil
+	}
+	if err := mediator.telepathyService.IncomingMessageAdded(mRetrieveConf); err != nil {
+		log.Println("Cannot notify telepathy-ofono about new message", err)
+	}
+
+	return mRetrieveConf, nil
+}
+
+//loadMRetrieveConf decodes the stored m-retrieve.conf for uuid
+func loadMRetrieveConf(uuid string) (*mms.MRetrieveConf, error) {
 	var filePath string
 	if f, err := storage.GetMMS(uuid); err == nil {
 		filePath = f
@@ -236,19 +568,10 @@ func (mediator *Mediator) handleMRetrieveConf(uuid string) (*mms.MRetrieveConf,
 	}
 
 	mRetrieveConf := mms.NewMRetrieveConf(uuid)
-	dec := mms.NewDecoder(mmsData)
+	dec := mms.NewDecoder(mmsData, mms.DefaultDecoderLimits)
 	if err := dec.Decode(mRetrieveConf); err != nil {
 		return nil, fmt.Errorf("unable to decode m-retrieve.conf: %s with log %s", err, dec.GetLog())
 	}
-
-	if mediator.telepathyService != nil {
-		if err := mediator.telepathyService.IncomingMessageAdded(mRetrieveConf); err != nil {
-			log.Println("Cannot notify telepathy-ofono about new message", err)
-		}
-	} else {
-		log.Print("Not sending recently retrieved message")
-	}
-
 	return mRetrieveConf, nil
 }
 
@@ -258,9 +581,33 @@ func (mediator *Mediator) handleMNotifyRespInd(mNotifyRespInd *mms.MNotifyRespIn
 		log.Print("Unable to create m-notifyresp.ind file for ", mNotifyRespInd.UUID)
 		return ""
 	}
+	return encodeToFile(f, mNotifyRespInd, "m-notifyresp.ind", mNotifyRespInd.UUID)
+}
+
+func (mediator *Mediator) handleMAcknowledgeInd(mAcknowledgeInd *mms.MAcknowledgeInd) string {
+	f, err := storage.CreateAcknowledgeFile(mAcknowledgeInd.UUID)
+	if err != nil {
+		log.Print("Unable to create m-acknowledge.ind file for ", mAcknowledgeInd.UUID)
+		return ""
+	}
+	return encodeToFile(f, mAcknowledgeInd, "m-acknowledge.ind", mAcknowledgeInd.UUID)
+}
+
+func (mediator *Mediator) handleMCancelConf(mCancelConf *mms.MCancelConf) string {
+	f, err := storage.CreateCancelFile(mCancelConf.UUID)
+	if err != nil {
+		log.Print("Unable to create m-cancel.conf file for ", mCancelConf.UUID)
+		return ""
+	}
+	return encodeToFile(f, mCancelConf, "m-cancel.conf", mCancelConf.UUID)
+}
+
+//encodeToFile encodes pdu into f and returns the path to it, an empty string
+//is returned if anything fails.
+func encodeToFile(f *os.File, pdu mms.MMSWriter, pduName, uuid string) string {
 	enc := mms.NewEncoder(f)
-	if err := enc.Encode(mNotifyRespInd); err != nil {
-		log.Print("Unable to encode m-notifyresp.ind for ", mNotifyRespInd.UUID)
+	if err := enc.Encode(pdu); err != nil {
+		log.Print("Unable to encode ", pduName, " for ", uuid)
 		f.Close()
 		return ""
 	}
@@ -273,11 +620,11 @@ func (mediator *Mediator) handleMNotifyRespInd(mNotifyRespInd *mms.MNotifyRespIn
 		log.Print("Error while closing", f.Name(), ": ", err)
 		return ""
 	}
-	log.Printf("Created %s to handle m-notifyresp.ind for %s", filePath, mNotifyRespInd.UUID)
+	log.Printf("Created %s to handle %s for %s", filePath, pduName, uuid)
 	return filePath
 }
 
-func (mediator *Mediator) sendMNotifyRespInd(filePath string, mmsContext *ofono.OfonoContext) {
+func (mediator *Mediator) sendResponse(filePath string, mmsContext *ofono.OfonoContext) {
 	defer os.Remove(filePath)
 
 	proxy, err := mmsContext.GetProxy()
@@ -292,8 +639,42 @@ func (mediator *Mediator) sendMNotifyRespInd(filePath string, mmsContext *ofono.
 	}
 
 	if _, err := mms.Upload(filePath, msc, proxy.Host, int32(proxy.Port)); err != nil {
-		log.Printf("Cannot upload m-notifyresp.ind encoded file %s to message center: %s", filePath, err)
+		log.Printf("Cannot upload encoded response file %s to message center: %s", filePath, err)
+	}
+}
+
+//handleMarkRead sends a m-read-rec.ind for the message identified by uuid if
+//its originator requested a read report.
+func (mediator *Mediator) handleMarkRead(uuid string) {
+	mRetrieveConf, err := loadMRetrieveConf(uuid)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if mRetrieveConf.ReadReport != mms.ReadReportYes {
+		return
+	}
+	mReadRecInd := mRetrieveConf.NewMReadRecInd(mms.ReadStatusRead)
+	filePath := mediator.handleMReadRecInd(mReadRecInd)
+	if filePath == "" {
+		return
+	}
+	defer os.Remove(filePath)
+	responseFile, err := mediator.uploadFile(filePath)
+	if err != nil {
+		log.Printf("Cannot upload m-read-rec.ind encoded file %s to message center: %s", filePath, err)
+		return
+	}
+	os.Remove(responseFile)
+}
+
+func (mediator *Mediator) handleMReadRecInd(mReadRecInd *mms.MReadRecInd) string {
+	f, err := storage.CreateReadReportFile(mReadRecInd.UUID)
+	if err != nil {
+		log.Print("Unable to create m-read-rec.ind file for ", mReadRecInd.UUID)
+		return ""
 	}
+	return encodeToFile(f, mReadRecInd, "m-read-rec.ind", mReadRecInd.UUID)
 }
 
 func (mediator *Mediator) handleOutgoingMessage(msg *telepathy.OutgoingMessage) {
@@ -302,19 +683,218 @@ func (mediator *Mediator) handleOutgoingMessage(msg *telepathy.OutgoingMessage)
 		ct, err := mms.NewAttachment(att.Id, att.ContentType, att.FilePath)
 		if err != nil {
 			log.Print(err)
-			//TODO reply to telepathy ofono with an error
+			mediator.replySendMessageError(msg, "Error.InvalidArguments", err.Error())
 			return
 		}
 		cts = append(cts, ct)
 	}
-	mSendReq := mms.NewMSendReq(msg.Recipients, cts, useDeliveryReports)
-	if _, err := mediator.telepathyService.ReplySendMessage(msg.Reply, mSendReq.UUID); err != nil {
+	options := msg.Options
+	mcc := mediator.modem.MobileCountryCode()
+	to, err := mms.NewAddresses(msg.Recipients, mcc)
+	if err != nil {
+		log.Print(err)
+		mediator.replySendMessageError(msg, "Error.InvalidArguments", err.Error())
+		return
+	}
+	cc, err := mms.NewAddresses(options.Cc, mcc)
+	if err != nil {
+		log.Print(err)
+		mediator.replySendMessageError(msg, "Error.InvalidArguments", err.Error())
+		return
+	}
+	bcc, err := mms.NewAddresses(options.Bcc, mcc)
+	if err != nil {
+		log.Print(err)
+		mediator.replySendMessageError(msg, "Error.InvalidArguments", err.Error())
+		return
+	}
+	mSendReq := mms.NewMSendReq(to, cts, options.DeliveryReport, options.ReadReport)
+	mSendReq.Subject = options.Subject
+	mSendReq.SetCc(cc)
+	mSendReq.SetBcc(bcc)
+	if options.Priority != 0 {
+		mSendReq.Priority = options.Priority
+	}
+	if options.Class != 0 {
+		mSendReq.Class = options.Class
+	}
+	if options.Expiry != 0 {
+		mSendReq.Expiry = options.Expiry
+	}
+	if options.SenderVisibility != 0 {
+		mSendReq.SenderVisibility = options.SenderVisibility
+	}
+	if _, err := mediator.telepathyService.ReplySendMessage(msg.Reply, mSendReq.UUID, mSendReq.To); err != nil {
 		log.Print(err)
 		return
 	}
 	mediator.NewMSendReq <- mSendReq
 }
 
+//handleForward forwards a received message with a m-forward.req when the
+//message center supports MMS 1.2 and still holds it, the stored message is
+//sent again with a m-send.req otherwise.
+func (mediator *Mediator) handleForward(msg *telepathy.ForwardMessage) {
+	to, err := mms.NewAddresses(msg.Recipients, mediator.modem.MobileCountryCode())
+	if err != nil {
+		log.Print(err)
+		mediator.replyForwardError(msg, "Error.InvalidArguments", err.Error())
+		return
+	}
+	contentLocation, err := storage.GetContentLocation(msg.UUID)
+	if err != nil {
+		log.Print("Cannot find the Content-Location for ", msg.UUID, ": ", err)
+	}
+	mRetrieveConf, err := loadMRetrieveConf(msg.UUID)
+	if err != nil {
+		log.Print(err)
+	}
+	version := mediator.messageCenterVersion(msg.UUID, mRetrieveConf)
+	if contentLocation == "" || version < mms.MMS_MESSAGE_VERSION_1_2 {
+		if mRetrieveConf == nil {
+			log.Print("Cannot forward ", msg.UUID, " as it is neither stored locally nor forwardable by the message center")
+			mediator.replyForwardError(msg, "Error.NotAllowed", "Message is neither stored nor forwardable by the message center")
+			return
+		}
+		log.Print("Message center cannot forward ", msg.UUID, ", sending it again")
+		mSendReq, err := mRetrieveConf.NewForwardMSendReq(to, useDeliveryReports, false)
+		if err != nil {
+			log.Print(err)
+			mediator.replyForwardError(msg, "Error.Failed", err.Error())
+			return
+		}
+		if _, err := mediator.telepathyService.ReplySendMessage(msg.Reply, mSendReq.UUID, mSendReq.To); err != nil {
+			log.Print(err)
+			return
+		}
+		mediator.NewMSendReq <- mSendReq
+		return
+	}
+	mForwardReq := mms.NewMForwardReq(to, contentLocation, useDeliveryReports, false)
+	if _, err := mediator.telepathyService.ReplySendMessage(msg.Reply, mForwardReq.UUID, mForwardReq.To); err != nil {
+		log.Print(err)
+		return
+	}
+	mediator.sendMForwardReq(mForwardReq, mRetrieveConf, to)
+}
+
+func (mediator *Mediator) replySendMessageError(msg *telepathy.OutgoingMessage, name, message string) {
+	if mediator.telepathyService == nil {
+		return
+	}
+	if err := mediator.telepathyService.ReplySendMessageError(msg, name, message); err != nil {
+		log.Println("Could not send reply:", err)
+	}
+}
+
+func (mediator *Mediator) replyForwardError(msg *telepathy.ForwardMessage, name, message string) {
+	if mediator.telepathyService == nil {
+		return
+	}
+	if err := mediator.telepathyService.ReplyForwardError(msg, name, message); err != nil {
+		log.Println("Could not send reply:", err)
+	}
+}
+
+//messageCenterVersion returns the MMS version the message center used for
+//the received message identified by uuid
+func (mediator *Mediator) messageCenterVersion(uuid string, mRetrieveConf *mms.MRetrieveConf) byte {
+	if mRetrieveConf != nil {
+		return mRetrieveConf.Version
+	}
+	mediator.deferredLock.Lock()
+	defer mediator.deferredLock.Unlock()
+	if mNotificationInd, ok := mediator.deferred[uuid]; ok {
+		return mNotificationInd.Version
+	}
+	return mms.MMS_MESSAGE_VERSION_1_0
+}
+
+//sendMForwardReq uploads mForwardReq, the stored mRetrieveConf is sent again
+//to recipients when the message center cannot forward it.
+func (mediator *Mediator) sendMForwardReq(mForwardReq *mms.MForwardReq, mRetrieveConf *mms.MRetrieveConf, recipients []mms.Address) {
+	uuid := mForwardReq.UUID
+	f, err := storage.CreateForwardFile(uuid)
+	if err != nil {
+		log.Print("Unable to create m-forward.req file for ", uuid)
+		return
+	}
+	mForwardReqFile := encodeToFile(f, mForwardReq, "m-forward.req", uuid)
+	if mForwardReqFile == "" {
+		if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.PERMANENT_ERROR); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	defer os.Remove(mForwardReqFile)
+
+	mForwardConfFile, err := mediator.uploadFile(mForwardReqFile)
+	if err != nil {
+		if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.TRANSIENT_ERROR); err != nil {
+			log.Println(err)
+		}
+		log.Printf("Cannot upload m-forward.req encoded file %s to message center: %s", mForwardReqFile, err)
+		mediator.telepathyService.MessageDestroy(uuid)
+		return
+	}
+	defer os.Remove(mForwardConfFile)
+	mForwardConf, err := parseMForwardConfFile(mForwardConfFile)
+	if err != nil {
+		log.Println("Error while decoding m-forward.conf:", err)
+		if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.DecodeErrorStatus(err)); err != nil {
+			log.Println(err)
+		}
+		mediator.telepathyService.MessageDestroy(uuid)
+		return
+	}
+
+	log.Println("m-forward.conf ResponseStatus for", uuid, "is", mForwardConf.ResponseStatus)
+	if forwardUnsupported(mForwardConf.ResponseStatus) && mRetrieveConf != nil {
+		log.Print("Message center did not forward ", uuid, ", sending it again")
+		mSendReq, err := mRetrieveConf.NewForwardMSendReq(recipients, mForwardReq.DeliveryReport == mms.DeliveryReportYes, false)
+		if err == nil {
+			mSendReq.UUID = uuid
+			mediator.handleMSendReq(mSendReq)
+			return
+		}
+		log.Print(err)
+	}
+	keepMessage := false
+	var status string
+	switch mForwardConf.Status() {
+	case nil:
+		status = telepathy.SENT
+		if err := storage.UpdateSent(uuid, mForwardConf.MessageId); err != nil {
+			log.Println("Can't update mms status:", err)
+		}
+		keepMessage = mForwardReq.DeliveryReport == mms.DeliveryReportYes || mForwardReq.ReadReport == mms.ReadReportYes
+	case mms.ErrPermanent:
+		status = telepathy.PERMANENT_ERROR
+	case mms.ErrTransient:
+		status = telepathy.TRANSIENT_ERROR
+	}
+	if err := mediator.telepathyService.MessageStatusChanged(uuid, status); err != nil {
+		log.Println(err)
+	}
+	if !keepMessage {
+		mediator.telepathyService.MessageDestroy(uuid)
+	}
+}
+
+//forwardUnsupported tells if a m-forward.conf status means the message center
+//cannot forward the message, either because it does not support it or because
+//it no longer holds the message
+func forwardUnsupported(responseStatus byte) bool {
+	switch responseStatus {
+	case mms.ResponseStatusErrorUnsupportedMessage,
+		mms.ResponseStatusErrorMessageNotFound,
+		mms.ResponseStatusErrorTransientMessageNotFound,
+		mms.ResponseStatusErrorPermanentMessageNotFound:
+		return true
+	}
+	return false
+}
+
 func (mediator *Mediator) handleMSendReq(mSendReq *mms.MSendReq) {
 	log.Print("Encoding M-Send.Req")
 	f, err := storage.CreateSendFile(mSendReq.UUID)
@@ -342,12 +922,22 @@ func (mediator *Mediator) handleMSendReq(mSendReq *mms.MSendReq) {
 		return
 	}
 	log.Printf("Created %s to handle m-send.req for %s", filePath, mSendReq.UUID)
-	mediator.sendMSendReq(filePath, mSendReq.UUID)
+	keepForReports := mSendReq.DeliveryReport == mms.DeliveryReportYes || mSendReq.ReadReport == mms.ReadReportYes
+	mediator.sendMSendReq(filePath, mSendReq.UUID, keepForReports)
 }
 
-func (mediator *Mediator) sendMSendReq(mSendReqFile, uuid string) {
+//sendMSendReq uploads the encoded m-send.req in mSendReqFile, keepForReports
+//tells if the message needs to be kept around to match reports requested
+//for it once it is sent.
+func (mediator *Mediator) sendMSendReq(mSendReqFile, uuid string, keepForReports bool) {
 	defer os.Remove(mSendReqFile)
-	defer mediator.telepathyService.MessageDestroy(uuid)
+	// messages waiting on reports are kept until the client deletes them
+	keepMessage := false
+	defer func() {
+		if !keepMessage {
+			mediator.telepathyService.MessageDestroy(uuid)
+		}
+	}()
 	mSendConfFile, err := mediator.uploadFile(mSendReqFile)
 	if err != nil {
 		if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.TRANSIENT_ERROR); err != nil {
@@ -361,7 +951,7 @@ func (mediator *Mediator) sendMSendReq(mSendReqFile, uuid string) {
 	mSendConf, err := parseMSendConfFile(mSendConfFile)
 	if err != nil {
 		log.Println("Error while decoding m-send.conf:", err)
-		if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.TRANSIENT_ERROR); err != nil {
+		if err := mediator.telepathyService.MessageStatusChanged(uuid, telepathy.DecodeErrorStatus(err)); err != nil {
 			log.Println(err)
 		}
 		return
@@ -372,6 +962,10 @@ func (mediator *Mediator) sendMSendReq(mSendReqFile, uuid string) {
 	switch mSendConf.Status() {
 	case nil:
 		status = telepathy.SENT
+		if err := storage.UpdateSent(uuid, mSendConf.MessageId); err != nil {
+			log.Println("Can't update mms status:", err)
+		}
+		keepMessage = keepForReports
 	case mms.ErrPermanent:
 		status = telepathy.PERMANENT_ERROR
 	case mms.ErrTransient:
@@ -390,13 +984,28 @@ func parseMSendConfFile(mSendConfFile string) (*mms.MSendConf, error) {
 
 	mSendConf := mms.NewMSendConf()
 
-	dec := mms.NewDecoder(b)
+	dec := mms.NewDecoder(b, mms.DefaultDecoderLimits)
 	if err := dec.Decode(mSendConf); err != nil {
 		return nil, err
 	}
 	return mSendConf, nil
 }
 
+func parseMForwardConfFile(mForwardConfFile string) (*mms.MForwardConf, error) {
+	b, err := ioutil.ReadFile(mForwardConfFile)
+	if err != nil {
+		return nil, err
+	}
+
+	mForwardConf := mms.NewMForwardConf()
+
+	dec := mms.NewDecoder(b, mms.DefaultDecoderLimits)
+	if err := dec.Decode(mForwardConf); err != nil {
+		return nil, err
+	}
+	return mForwardConf, nil
+}
+
 func (mediator *Mediator) uploadFile(filePath string) (string, error) {
 	mediator.contextLock.Lock()
 	defer mediator.contextLock.Unlock()
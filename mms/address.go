@@ -0,0 +1,392 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mms
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+)
+
+// AddressType identifies how an address is encapsulated in a PDU as defined
+// in OMA-TS-MMS-ENC section 8
+type AddressType int
+
+const (
+	AddressPLMN AddressType = iota
+	AddressIPv4
+	AddressIPv6
+	AddressEmail
+	AddressShortCode
+	AddressOther
+)
+
+const addressTypePrefix = "/TYPE="
+
+// maxShortCodeLength is the longest number that is sent as dialed instead of
+// being normalized to E.164
+const maxShortCodeLength = 6
+
+// Address is a sender or recipient address. Value holds the address without
+// its type suffix and TypeName holds the address type for AddressOther
+// addresses.
+type Address struct {
+	Type     AddressType
+	Value    string
+	TypeName string
+}
+
+// ParseAddress parses an address as it is encoded in a PDU, device addresses
+// carry a /TYPE= suffix while e-mail addresses and short codes are sent as
+// they are.
+func ParseAddress(s string) (Address, error) {
+	if s == "" {
+		return Address{}, fmt.Errorf("empty address")
+	}
+	if value, typeName, ok := splitAddressType(s); ok {
+		switch strings.ToUpper(typeName) {
+		case "PLMN":
+			if !isGlobalPhoneNumber(value) {
+				return Address{}, fmt.Errorf("invalid PLMN address %q", s)
+			}
+			return Address{Type: AddressPLMN, Value: value}, nil
+		case "IPV4":
+			if ip := net.ParseIP(value); ip == nil || strings.Contains(value, ":") {
+				return Address{}, fmt.Errorf("invalid IPv4 address %q", s)
+			}
+			return Address{Type: AddressIPv4, Value: value}, nil
+		case "IPV6":
+			if ip := net.ParseIP(value); ip == nil || !strings.Contains(value, ":") {
+				return Address{}, fmt.Errorf("invalid IPv6 address %q", s)
+			}
+			return Address{Type: AddressIPv6, Value: value}, nil
+		default:
+			if value == "" || !isAddressTypeName(typeName) {
+				return Address{}, fmt.Errorf("invalid address %q", s)
+			}
+			return Address{Type: AddressOther, Value: value, TypeName: typeName}, nil
+		}
+	}
+	if strings.Contains(s, "@") {
+		if _, err := mail.ParseAddress(s); err != nil {
+			return Address{}, fmt.Errorf("invalid e-mail address %q: %s", s, err)
+		}
+		return Address{Type: AddressEmail, Value: s}, nil
+	}
+	if isShortCode(s) {
+		return Address{Type: AddressShortCode, Value: s}, nil
+	}
+	return Address{}, fmt.Errorf("invalid address %q", s)
+}
+
+// NewAddress creates an Address from a user supplied recipient. Values with a
+// type suffix are parsed with ParseAddress, e-mail addresses are formatted as
+// RFC 822 addresses, alphanumeric values are short codes and anything else is
+// a phone number that is normalized with NormalizeE164 using mcc, the mobile
+// country code of the SIM. Short numbers are kept as dialed but still sent as
+// PLMN addresses as the address grammar of the MMS versions used for sending
+// has no form for numeric short codes.
+func NewAddress(s, mcc string) (Address, error) {
+	s = strings.TrimSpace(s)
+	if _, _, ok := splitAddressType(s); ok {
+		return ParseAddress(s)
+	}
+	if strings.Contains(s, "@") {
+		return newEmailAddress(s)
+	}
+	number := stripWrittenSeparators(s)
+	if isDigits(number) && len(number) <= maxShortCodeLength {
+		return Address{Type: AddressPLMN, Value: number}, nil
+	}
+	if isShortCode(number) && !isDigits(number) {
+		return Address{Type: AddressShortCode, Value: number}, nil
+	}
+	number = NormalizeE164(number, mcc)
+	if !isGlobalPhoneNumber(number) {
+		return Address{}, fmt.Errorf("invalid address %q", s)
+	}
+	return Address{Type: AddressPLMN, Value: number}, nil
+}
+
+// newEmailAddress formats s as an RFC 822 address, a display name that is not
+// plain ASCII is encoded following RFC 2047
+func newEmailAddress(s string) (Address, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid e-mail address %q: %s", s, err)
+	}
+	if addr.Name == "" {
+		return Address{Type: AddressEmail, Value: addr.Address}, nil
+	}
+	return Address{Type: AddressEmail, Value: addr.String()}, nil
+}
+
+// NewAddresses creates an Address for each of recipients with NewAddress
+func NewAddresses(recipients []string, mcc string) ([]Address, error) {
+	addresses := make([]Address, 0, len(recipients))
+	for _, recipient := range recipients {
+		address, err := NewAddress(recipient, mcc)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// String returns the address encoded as it is sent in a PDU
+func (address Address) String() string {
+	switch address.Type {
+	case AddressPLMN:
+		return address.Value + addressTypePrefix + "PLMN"
+	case AddressIPv4:
+		return address.Value + addressTypePrefix + "IPv4"
+	case AddressIPv6:
+		return address.Value + addressTypePrefix + "IPv6"
+	case AddressOther:
+		return address.Value + addressTypePrefix + address.TypeName
+	default:
+		return address.Value
+	}
+}
+
+// formatAddresses encodes addresses as they are sent in a PDU
+func formatAddresses(addresses []Address) []string {
+	if len(addresses) == 0 {
+		return nil
+	}
+	encoded := make([]string, len(addresses))
+	for i := range addresses {
+		encoded[i] = addresses[i].String()
+	}
+	return encoded
+}
+
+// dialPlan holds the numbering details needed to turn a national number into
+// an E.164 one, nationalLength is only set where the trunk prefix is optional
+type dialPlan struct {
+	callingCode         string
+	trunkPrefix         string
+	internationalPrefix string
+	nationalLength      int
+}
+
+var nanp = dialPlan{"1", "1", "011", 10}
+
+// dialPlans maps mobile country codes to their dial plan
+var dialPlans = map[string]dialPlan{
+	"202": {"30", "", "00", 0},
+	"204": {"31", "0", "00", 0},
+	"206": {"32", "0", "00", 0},
+	"208": {"33", "0", "00", 0},
+	"212": {"377", "", "00", 0},
+	"213": {"376", "", "00", 0},
+	"214": {"34", "", "00", 0},
+	"216": {"36", "06", "00", 0},
+	"218": {"387", "0", "00", 0},
+	"219": {"385", "0", "00", 0},
+	"220": {"381", "0", "00", 0},
+	"222": {"39", "", "00", 0},
+	"226": {"40", "0", "00", 0},
+	"228": {"41", "0", "00", 0},
+	"230": {"420", "", "00", 0},
+	"231": {"421", "0", "00", 0},
+	"232": {"43", "0", "00", 0},
+	"234": {"44", "0", "00", 0},
+	"235": {"44", "0", "00", 0},
+	"238": {"45", "", "00", 0},
+	"240": {"46", "0", "00", 0},
+	"242": {"47", "", "00", 0},
+	"244": {"358", "0", "00", 0},
+	"247": {"371", "", "00", 0},
+	"248": {"372", "", "00", 0},
+	"250": {"7", "8", "810", 0},
+	"255": {"380", "0", "00", 0},
+	"257": {"375", "8", "810", 0},
+	"259": {"373", "0", "00", 0},
+	"260": {"48", "", "00", 0},
+	"262": {"49", "0", "00", 0},
+	"266": {"350", "", "00", 0},
+	"268": {"351", "", "00", 0},
+	"270": {"352", "", "00", 0},
+	"272": {"353", "0", "00", 0},
+	"274": {"354", "", "00", 0},
+	"276": {"355", "0", "00", 0},
+	"278": {"356", "", "00", 0},
+	"280": {"357", "", "00", 0},
+	"282": {"995", "0", "00", 0},
+	"283": {"374", "0", "00", 0},
+	"284": {"359", "0", "00", 0},
+	"286": {"90", "0", "00", 0},
+	"293": {"386", "0", "00", 0},
+	"294": {"389", "0", "00", 0},
+	"297": {"382", "0", "00", 0},
+	"302": nanp,
+	"310": nanp,
+	"311": nanp,
+	"312": nanp,
+	"313": nanp,
+	"314": nanp,
+	"315": nanp,
+	"316": nanp,
+	"330": nanp,
+	"334": {"52", "", "00", 0},
+	"404": {"91", "0", "00", 0},
+	"405": {"91", "0", "00", 0},
+	"410": {"92", "0", "00", 0},
+	"420": {"966", "0", "00", 0},
+	"424": {"971", "0", "00", 0},
+	"425": {"972", "0", "00", 0},
+	"440": {"81", "0", "010", 0},
+	"441": {"81", "0", "010", 0},
+	"450": {"82", "0", "00", 0},
+	"452": {"84", "0", "00", 0},
+	"454": {"852", "", "00", 0},
+	"455": {"853", "", "00", 0},
+	"460": {"86", "0", "00", 0},
+	"466": {"886", "0", "00", 0},
+	"502": {"60", "0", "00", 0},
+	"505": {"61", "0", "0011", 0},
+	"510": {"62", "0", "00", 0},
+	"515": {"63", "0", "00", 0},
+	"520": {"66", "0", "00", 0},
+	"525": {"65", "", "00", 0},
+	"530": {"64", "0", "00", 0},
+	"602": {"20", "0", "00", 0},
+	"604": {"212", "0", "00", 0},
+	"621": {"234", "0", "00", 0},
+	"639": {"254", "0", "00", 0},
+	"655": {"27", "0", "00", 0},
+	"730": {"56", "", "00", 0},
+	"732": {"57", "", "00", 0},
+}
+
+// NormalizeE164 returns number in E.164 format using the dial plan of the
+// country identified by mcc. Numbers that are already international are only
+// stripped of written separators and numbers that cannot be normalized, such
+// as those for an unknown mcc, are returned unchanged.
+func NormalizeE164(number, mcc string) string {
+	number = stripWrittenSeparators(number)
+	if strings.HasPrefix(number, "+") || !isDigits(number) {
+		return number
+	}
+	plan, ok := dialPlans[mcc]
+	if !ok {
+		if strings.HasPrefix(number, "00") {
+			return "+" + number[2:]
+		}
+		return number
+	}
+	switch {
+	case strings.HasPrefix(number, plan.internationalPrefix):
+		return "+" + number[len(plan.internationalPrefix):]
+	case plan.nationalLength != 0 && len(number) == plan.nationalLength:
+		return "+" + plan.callingCode + number
+	case plan.trunkPrefix != "" && strings.HasPrefix(number, plan.trunkPrefix):
+		return "+" + plan.callingCode + number[len(plan.trunkPrefix):]
+	case plan.trunkPrefix == "" && len(number) > maxShortCodeLength:
+		return "+" + plan.callingCode + number
+	}
+	return number
+}
+
+// splitAddressType splits an encoded device address into its value and type
+func splitAddressType(s string) (value, typeName string, ok bool) {
+	i := strings.LastIndex(s, "/")
+	if i == -1 || len(s)-i < len(addressTypePrefix) {
+		return "", "", false
+	}
+	if !strings.EqualFold(s[i:i+len(addressTypePrefix)], addressTypePrefix) {
+		return "", "", false
+	}
+	return s[:i], s[i+len(addressTypePrefix):], true
+}
+
+// isGlobalPhoneNumber checks for ["+"] 1*( DIGIT / written-sep )
+func isGlobalPhoneNumber(s string) bool {
+	s = strings.TrimPrefix(s, "+")
+	if s == "" {
+		return false
+	}
+	digits := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '-' || r == '.':
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// isShortCode checks for an alphanumeric or numeric short code
+func isShortCode(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isAddressTypeName checks for 1*( ALPHA / DIGIT / "_" )
+func isAddressTypeName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || isShortCode(string(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// stripWrittenSeparators removes the characters commonly used when writing
+// down a phone number
+func stripWrittenSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, s)
+}
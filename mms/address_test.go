@@ -0,0 +1,136 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mms
+
+import . "launchpad.net/gocheck"
+
+type AddressTestSuite struct{}
+
+var _ = Suite(&AddressTestSuite{})
+
+func (s *AddressTestSuite) TestParseAddress(c *C) {
+	addresses := []struct {
+		encoded string
+		address Address
+	}{
+		{"+12345/TYPE=PLMN", Address{Type: AddressPLMN, Value: "+12345"}},
+		{"+1-234.5/TYPE=PLMN", Address{Type: AddressPLMN, Value: "+1-234.5"}},
+		{"192.168.0.1/TYPE=IPv4", Address{Type: AddressIPv4, Value: "192.168.0.1"}},
+		{"2001:db8:0:0:0:0:0:1/TYPE=IPv6", Address{Type: AddressIPv6, Value: "2001:db8:0:0:0:0:0:1"}},
+		{"user@example.com", Address{Type: AddressEmail, Value: "user@example.com"}},
+		{"Jane Doe <jane@example.com>", Address{Type: AddressEmail, Value: "Jane Doe <jane@example.com>"}},
+		{"12345", Address{Type: AddressShortCode, Value: "12345"}},
+		{"INFO", Address{Type: AddressShortCode, Value: "INFO"}},
+		{"id42/TYPE=X_APP", Address{Type: AddressOther, Value: "id42", TypeName: "X_APP"}},
+	}
+	for _, a := range addresses {
+		address, err := ParseAddress(a.encoded)
+		c.Assert(err, IsNil, Commentf(a.encoded))
+		c.Check(address, DeepEquals, a.address)
+		c.Check(address.String(), Equals, a.encoded)
+	}
+}
+
+func (s *AddressTestSuite) TestParseAddressCanonicalType(c *C) {
+	address, err := ParseAddress("+12345/type=plmn")
+	c.Assert(err, IsNil)
+	c.Check(address, DeepEquals, Address{Type: AddressPLMN, Value: "+12345"})
+	c.Check(address.String(), Equals, "+12345/TYPE=PLMN")
+}
+
+func (s *AddressTestSuite) TestParseAddressInvalid(c *C) {
+	invalid := []string{
+		"",
+		"+12a45/TYPE=PLMN",
+		"/TYPE=PLMN",
+		"300.1.1.1/TYPE=IPv4",
+		"192.168.0.1/TYPE=IPv6",
+		"::1/TYPE=IPv4",
+		"id42/TYPE=",
+		"id42/TYPE=X-APP",
+		"not an@address@",
+		"+12345",
+	}
+	for _, encoded := range invalid {
+		_, err := ParseAddress(encoded)
+		c.Check(err, NotNil, Commentf(encoded))
+	}
+}
+
+func (s *AddressTestSuite) TestNewAddress(c *C) {
+	addresses := []struct {
+		input   string
+		mcc     string
+		encoded string
+	}{
+		{"+44 7700 900123", "234", "+447700900123/TYPE=PLMN"},
+		{"07700 900123", "234", "+447700900123/TYPE=PLMN"},
+		{"(555) 123-4567", "310", "+15551234567/TYPE=PLMN"},
+		{"1 555 123 4567", "310", "+15551234567/TYPE=PLMN"},
+		{"011 44 7700 900123", "310", "+447700900123/TYPE=PLMN"},
+		{"0044 7700 900123", "", "+447700900123/TYPE=PLMN"},
+		{"333 1234567", "222", "+393331234567/TYPE=PLMN"},
+		{"5551234567", "", "5551234567/TYPE=PLMN"},
+		{"12345", "310", "12345/TYPE=PLMN"},
+		{"123 456", "", "123456/TYPE=PLMN"},
+		{"INFO", "234", "INFO"},
+		{"user@example.com", "234", "user@example.com"},
+		{"<user@example.com>", "", "user@example.com"},
+		{"Jane Doe <jane@example.com>", "", `"Jane Doe" <jane@example.com>`},
+		{"José <jose@example.com>", "", "=?utf-8?q?Jos=C3=A9?= <jose@example.com>"},
+		{"+12345/TYPE=PLMN", "234", "+12345/TYPE=PLMN"},
+	}
+	for _, a := range addresses {
+		address, err := NewAddress(a.input, a.mcc)
+		c.Assert(err, IsNil, Commentf(a.input))
+		c.Check(address.String(), Equals, a.encoded, Commentf(a.input))
+	}
+}
+
+func (s *AddressTestSuite) TestNewMSendReqShortCode(c *C) {
+	recipients, err := NewAddresses([]string{"12345", "INFO"}, "310")
+	c.Assert(err, IsNil)
+	mSendReq := NewMSendReq(recipients, []*Attachment{}, false, false)
+	c.Check(mSendReq.Version, Equals, byte(MMS_MESSAGE_VERSION_1_1))
+	c.Check(mSendReq.To, DeepEquals, []string{"12345/TYPE=PLMN", "INFO"})
+}
+
+func (s *AddressTestSuite) TestNewAddressesInvalid(c *C) {
+	addresses, err := NewAddresses([]string{"+12345", "+12 ab 45"}, "")
+	c.Check(err, ErrorMatches, `invalid address "\+12 ab 45"`)
+	c.Check(addresses, IsNil)
+}
+
+func (s *AddressTestSuite) TestDecodeFromCanonicalAddress(c *C) {
+	inputBytes := []byte{
+		//Message Type m-read-orig.ind
+		0x8C, 0x88,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// From "+2/type=plmn"
+		0x89, 0x0e, 0x80, 0x2b, 0x32, 0x2f, 0x74, 0x79, 0x70, 0x65, 0x3d, 0x70, 0x6c, 0x6d, 0x6e, 0x00,
+	}
+	mReadOrigInd := NewMReadOrigInd()
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.Decode(mReadOrigInd), IsNil)
+	c.Check(mReadOrigInd.From, Equals, "+2/TYPE=PLMN")
+}
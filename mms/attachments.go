@@ -41,18 +41,38 @@ type Attachment struct {
 	Domain           string `encode:"no"`
 	Path             string `encode:"no"`
 	Comment          string `encode:"no"`
+	Differences      string `encode:"no"`
+	Mac              string `encode:"no"`
 	ContentLocation  string
 	ContentId        string
-	Level            byte    `encode:"no"`
-	Length           uint64  `encode:"no"`
-	Size             uint64  `encode:"no"`
-	CreationDate     uint64  `encode:"no"`
-	ModificationDate uint64  `encode:"no"`
-	ReadDate         uint64  `encode:"no"`
-	Offset           int     `encode:"no"`
-	Secure           bool    `encode:"no"`
-	Q                float64 `encode:"no"`
-	Data             []byte  `encode:"no"`
+	Level            byte              `encode:"no"`
+	Sec              byte              `encode:"no"`
+	Length           uint64            `encode:"no"`
+	Size             uint64            `encode:"no"`
+	CreationDate     uint64            `encode:"no"`
+	ModificationDate uint64            `encode:"no"`
+	ReadDate         uint64            `encode:"no"`
+	MaxAge           uint64            `encode:"no"`
+	Offset           int               `encode:"no"`
+	Secure           bool              `encode:"no"`
+	Q                float64           `encode:"no"`
+	Parameters       map[string]string `encode:"no"`
+	Data             []byte            `encode:"no"`
+	Parts            []Attachment      `encode:"no"`
+}
+
+//untypedParameterFields maps the names of untyped parameters to the fields
+//holding their typed counterparts
+var untypedParameterFields = map[string]string{
+	"type":       "Type",
+	"name":       "Name",
+	"filename":   "FileName",
+	"charset":    "Charset",
+	"start":      "Start",
+	"start-info": "StartInfo",
+	"domain":     "Domain",
+	"path":       "Path",
+	"comment":    "Comment",
 }
 
 func NewAttachment(id, contentType, filePath string) (*Attachment, error) {
@@ -105,77 +125,205 @@ func getSmilStart(smilData []byte) (string, error) {
 
 //GetSmil returns the text corresponding to the ContentType that holds the SMIL
 func (pdu *MRetrieveConf) GetSmil() (string, error) {
-	for i := range pdu.Attachments {
-		if strings.HasPrefix(pdu.Attachments[i].MediaType, "application/smil") {
-			return string(pdu.Attachments[i].Data), nil
+	attachments := FlattenAttachments(pdu.Attachments)
+	for i := range attachments {
+		if strings.HasPrefix(attachments[i].MediaType, "application/smil") {
+			return string(attachments[i].Data), nil
 		}
 	}
 	return "", errors.New("cannot find SMIL data part")
 }
 
-//GetDataParts returns the non SMIL ContentType data parts
+//GetDataParts returns the non SMIL ContentType data parts, parts nested in
+//a multipart are returned in place of it
 func (pdu *MRetrieveConf) GetDataParts() []Attachment {
 	var dataParts []Attachment
-	for i := range pdu.Attachments {
-		if pdu.Attachments[i].MediaType == "application/smil" {
+	attachments := FlattenAttachments(pdu.Attachments)
+	for i := range attachments {
+		if attachments[i].MediaType == "application/smil" {
 			continue
 		}
-		dataParts = append(dataParts, pdu.Attachments[i])
+		dataParts = append(dataParts, attachments[i])
+	}
+	return dataParts
+}
+
+//FlattenAttachments returns the leaf parts of attachments by replacing the
+//decoded multipart parts with their Parts recursively, Offset in each of them
+//still refers to the position in the whole PDU.
+func FlattenAttachments(attachments []Attachment) []Attachment {
+	var leaves []Attachment
+	for i := range attachments {
+		if attachments[i].IsMultipart() && attachments[i].Parts != nil {
+			leaves = append(leaves, FlattenAttachments(attachments[i].Parts)...)
+		} else {
+			leaves = append(leaves, attachments[i])
+		}
+	}
+	return leaves
+}
+
+//IsMultipart tells if the attachment holds a multipart body
+func (attachment *Attachment) IsMultipart() bool {
+	return strings.HasPrefix(attachment.MediaType, "application/vnd.wap.multipart.")
+}
+
+//GetTextDataParts returns the same data parts as GetDataParts but with the
+//Data of text parts converted to UTF-8 from their charset. Offset still refers
+//to the original bytes which together with the charset remain available
+//through GetDataParts. Text parts in a charset that cannot be converted are
+//left as they are.
+func (pdu *MRetrieveConf) GetTextDataParts() []Attachment {
+	dataParts := pdu.GetDataParts()
+	for i := range dataParts {
+		if !dataParts[i].IsText() || dataParts[i].Charset == "" {
+			continue
+		}
+		if err := dataParts[i].convertToUTF8(); err != nil {
+			log.Printf("Leaving part %s as %s: %s", dataParts[i].ContentId, dataParts[i].Charset, err)
+		}
 	}
 	return dataParts
 }
 
+//convertToUTF8 replaces the Data of a text attachment with its UTF-8
+//conversion and updates MediaType and Charset to match, the attachment is
+//left untouched if the conversion fails.
+func (attachment *Attachment) convertToUTF8() error {
+	text, err := attachment.GetText()
+	if err != nil {
+		return err
+	}
+	mediaType := strings.SplitN(attachment.MediaType, ";", 2)[0]
+	attachment.MediaType = mediaType + ";charset=utf-8"
+	attachment.Charset = "utf-8"
+	attachment.Data = []byte(text)
+	return nil
+}
+
+//IsText tells if the attachment holds a text/* media type
+func (attachment *Attachment) IsText() bool {
+	return strings.HasPrefix(attachment.MediaType, "text/")
+}
+
+//GetText returns the attachment data converted to UTF-8 from its charset
+func (attachment *Attachment) GetText() (string, error) {
+	return DecodeCharset(attachment.Charset, attachment.Data)
+}
+
 func (dec *MMSDecoder) ReadAttachmentParts(reflectedPdu *reflect.Value) error {
+	attachments := reflectedPdu.FieldByName("Attachments")
+	if !attachments.IsValid() {
+		return fmt.Errorf("%s cannot hold attachments", reflectedPdu.Type())
+	}
+	dataParts, err := dec.readParts(len(dec.Data))
+	if err != nil {
+		return err
+	}
+	if attachments.Type() == reflect.TypeOf([]*Attachment{}) {
+		// outgoing messages hold their attachments by reference
+		refs := make([]*Attachment, len(dataParts))
+		for i := range dataParts {
+			refs[i] = &dataParts[i]
+		}
+		attachments.Set(reflect.ValueOf(refs))
+		return nil
+	}
+	attachments.Set(reflect.ValueOf(dataParts))
+
+	return nil
+}
+
+//readParts reads a multipart body that finishes before end, parts holding a
+//multipart themselves are decoded recursively into their Parts.
+func (dec *MMSDecoder) readParts(end int) ([]Attachment, error) {
 	var err error
 	var parts uint64
+	dec.nesting++
+	defer func() { dec.nesting-- }()
+	if dec.limits.MaxNesting > 0 && dec.nesting > dec.limits.MaxNesting {
+		return nil, fmt.Errorf("%w, multipart nested deeper than %d", ErrLimitExceeded, dec.limits.MaxNesting)
+	}
+	if dec.Offset+1 >= end {
+		return nil, &TruncatedError{Offset: dec.Offset, Needed: 1, Length: end}
+	}
 	if parts, err = dec.ReadUintVar(nil, ""); err != nil {
-		return err
+		return nil, err
 	}
-	var dataParts []Attachment
+	if dec.limits.MaxParts > 0 && parts > dec.limits.MaxParts {
+		return nil, fmt.Errorf("%w, %d parts is more than %d", ErrLimitExceeded, parts, dec.limits.MaxParts)
+	}
+	dataParts := []Attachment{}
 	dec.log = dec.log + fmt.Sprintf("Number of parts: %d\n", parts)
 	for i := uint64(0); i < parts; i++ {
 		headerLen, err := dec.ReadUintVar(nil, "")
 		if err != nil {
-			return err
+			return nil, err
 		}
 		dataLen, err := dec.ReadUintVar(nil, "")
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if headerLen > uint64(end) || dataLen > uint64(end) || dec.Offset+int(headerLen+dataLen) >= end {
+			return nil, &TruncatedError{Offset: dec.Offset, Needed: int(headerLen + dataLen), Length: end}
 		}
 		headerEnd := dec.Offset + int(headerLen)
 		dec.log = dec.log + fmt.Sprintf("Attachament len(header): %d - len(data) %d\n", headerLen, dataLen)
 		var ct Attachment
 		ct.Offset = headerEnd + 1
 		ctReflected := reflect.ValueOf(&ct).Elem()
-		if err := dec.ReadAttachment(&ctReflected); err == nil {
-			if err := dec.ReadMMSHeaders(&ctReflected, headerEnd); err != nil {
-				return err
-			}
-		} else if err != nil && err.Error() != "WAP message" { //TODO create error type
-			return err
+		if err := dec.ReadAttachment(&ctReflected); err != nil {
+			return nil, err
+		}
+		if err := dec.ReadMMSHeaders(&ctReflected, headerEnd); err != nil {
+			return nil, err
 		}
 		dec.Offset = headerEnd + 1
 		if _, err := dec.ReadBoundedBytes(&ctReflected, "Data", dec.Offset+int(dataLen)); err != nil {
-			return err
+			return nil, err
 		}
 		if ct.MediaType == "application/smil" || strings.HasPrefix(ct.MediaType, "text/plain") || ct.MediaType == "" {
 			dec.log = dec.log + fmt.Sprintf("%s\n", ct.Data)
 		}
+		if ct.IsMultipart() {
+			if err := dec.readNestedParts(&ct); err != nil {
+				return nil, err
+			}
+		}
 		if ct.Charset != "" {
 			ct.MediaType = ct.MediaType + ";charset=" + ct.Charset
 		}
 		dataParts = append(dataParts, ct)
 	}
-	dataPartsR := reflect.ValueOf(dataParts)
-	reflectedPdu.FieldByName("Attachments").Set(dataPartsR)
+	return dataParts, nil
+}
 
+//readNestedParts decodes the multipart body held by ct into its Parts, a body
+//that cannot be decoded is kept as an opaque part unless a limit is exceeded.
+func (dec *MMSDecoder) readNestedParts(ct *Attachment) error {
+	dataEnd := dec.Offset
+	dec.Offset = ct.Offset - 1
+	parts, err := dec.readParts(dataEnd + 1)
+	if errors.Is(err, ErrLimitExceeded) {
+		return err
+	} else if err != nil {
+		log.Printf("Keeping %s part at offset %d undecoded: %s", ct.MediaType, ct.Offset, err)
+	} else {
+		ct.Parts = parts
+	}
+	dec.Offset = dataEnd
 	return nil
 }
 
 func (dec *MMSDecoder) ReadMMSHeaders(ctMember *reflect.Value, headerEnd int) error {
 	for dec.Offset < headerEnd {
-		var err error
-		param, _ := dec.ReadInteger(nil, "")
+		if err := dec.countHeader(); err != nil {
+			return err
+		}
+		param, err := dec.ReadInteger(nil, "")
+		if err != nil {
+			return err
+		}
 		switch param {
 		case MMS_PART_CONTENT_LOCATION:
 			_, err = dec.ReadString(ctMember, "ContentLocation")
@@ -215,16 +363,31 @@ func (dec *MMSDecoder) ReadAttachment(ctMember *reflect.Value) error {
 	}
 
 	for dec.Offset < len(dec.Data) && dec.Offset < endOffset {
-		param, _ := dec.ReadInteger(nil, "")
+		if err := dec.checkAvailable(1); err != nil {
+			return err
+		}
+		if next := dec.Data[dec.Offset+1]; next >= TEXT_MIN && next <= TEXT_MAX {
+			if err := dec.ReadUntypedParameter(ctMember); err != nil {
+				return err
+			}
+			continue
+		}
+		param, err := dec.ReadInteger(nil, "")
+		if err != nil {
+			return err
+		}
 		switch param {
 		case WSP_PARAMETER_TYPE_Q:
 			err = dec.ReadQ(ctMember)
 		case WSP_PARAMETER_TYPE_CHARSET:
 			_, err = dec.ReadCharset(ctMember, "Charset")
 		case WSP_PARAMETER_TYPE_LEVEL:
-			_, err = dec.ReadShortInteger(ctMember, "Level")
+			err = dec.readVersionValue(ctMember, "Level")
 		case WSP_PARAMETER_TYPE_TYPE:
-			_, err = dec.ReadInteger(ctMember, "Type")
+			var v uint64
+			if v, err = dec.ReadInteger(nil, ""); err == nil {
+				dec.setPduField(ctMember, "Type", contentTypeName(v), setterString)
+			}
 		case WSP_PARAMETER_TYPE_NAME_DEFUNCT:
 			log.Println("Using deprecated Name header")
 			_, err = dec.ReadString(ctMember, "Name")
@@ -232,11 +395,11 @@ func (dec *MMSDecoder) ReadAttachment(ctMember *reflect.Value) error {
 			log.Println("Using deprecated FileName header")
 			_, err = dec.ReadString(ctMember, "FileName")
 		case WSP_PARAMETER_TYPE_DIFFERENCES:
-			err = errors.New("Unhandled Differences")
+			err = dec.readFieldName(ctMember, "Differences")
 		case WSP_PARAMETER_TYPE_PADDING:
-			dec.ReadShortInteger(nil, "")
+			_, err = dec.ReadShortInteger(nil, "")
 		case WSP_PARAMETER_TYPE_CONTENT_TYPE:
-			_, err = dec.ReadString(ctMember, "Type")
+			err = dec.readConstrainedMedia(ctMember, "Type")
 		case WSP_PARAMETER_TYPE_START_DEFUNCT:
 			log.Println("Using deprecated Start header")
 			_, err = dec.ReadString(ctMember, "Start")
@@ -250,21 +413,26 @@ func (dec *MMSDecoder) ReadAttachment(ctMember *reflect.Value) error {
 			log.Println("Using deprecated Domain header")
 			_, err = dec.ReadString(ctMember, "Domain")
 		case WSP_PARAMETER_TYPE_MAX_AGE:
-			err = errors.New("Unhandled Max Age")
+			_, err = dec.ReadInteger(ctMember, "MaxAge")
 		case WSP_PARAMETER_TYPE_PATH_DEFUNCT:
 			log.Println("Using deprecated Path header")
 			_, err = dec.ReadString(ctMember, "Path")
 		case WSP_PARAMETER_TYPE_SECURE:
-			log.Println("Unhandled Secure header detected")
+			// No-value
+			if _, err = dec.ReadByte(nil, ""); err == nil {
+				ctMember.FieldByName("Secure").SetBool(true)
+			}
 		case WSP_PARAMETER_TYPE_SEC:
-			v, _ := dec.ReadShortInteger(nil, "")
-			log.Println("Using deprecated and unhandled Sec header with value", v)
+			log.Println("Using deprecated Sec header")
+			_, err = dec.ReadShortInteger(ctMember, "Sec")
 		case WSP_PARAMETER_TYPE_MAC:
-			err = errors.New("Unhandled MAC")
+			_, err = dec.ReadString(ctMember, "Mac")
 		case WSP_PARAMETER_TYPE_CREATION_DATE:
+			_, err = dec.ReadLongInteger(ctMember, "CreationDate")
 		case WSP_PARAMETER_TYPE_MODIFICATION_DATE:
+			_, err = dec.ReadLongInteger(ctMember, "ModificationDate")
 		case WSP_PARAMETER_TYPE_READ_DATE:
-			err = errors.New("Unhandled Date parameters")
+			_, err = dec.ReadLongInteger(ctMember, "ReadDate")
 		case WSP_PARAMETER_TYPE_SIZE:
 			_, err = dec.ReadInteger(ctMember, "Size")
 		case WSP_PARAMETER_TYPE_NAME:
@@ -281,11 +449,8 @@ func (dec *MMSDecoder) ReadAttachment(ctMember *reflect.Value) error {
 			_, err = dec.ReadString(ctMember, "Domain")
 		case WSP_PARAMETER_TYPE_PATH:
 			_, err = dec.ReadString(ctMember, "Path")
-		case WSP_PARAMETER_TYPE_UNTYPED:
-			v, _ := dec.ReadString(nil, "")
-			log.Println("Unhandled Secure header detected with value", v)
 		default:
-			err = fmt.Errorf("Unhandled parameter %#x == %d at offset %d", param, param, dec.Offset)
+			err = fmt.Errorf("%w parameter %#x == %d at offset %d", ErrUnsupportedHeader, param, param, dec.Offset)
 		}
 		if err != nil {
 			return err
@@ -293,3 +458,118 @@ func (dec *MMSDecoder) ReadAttachment(ctMember *reflect.Value) error {
 	}
 	return nil
 }
+
+// ReadUntypedParameter reads a content type parameter given by name, the ones
+// matching a typed parameter are stored in its field and the rest are kept in
+// Parameters.
+//
+// Untyped-parameter = Token-text Untyped-value
+// Untyped-value = Integer-value | Text-value
+func (dec *MMSDecoder) ReadUntypedParameter(ctMember *reflect.Value) error {
+	name, err := dec.ReadString(nil, "")
+	if err != nil {
+		return err
+	}
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
+	var value string
+	switch next := dec.Data[dec.Offset+1]; {
+	case next == 0:
+		// No-value
+		_, err = dec.ReadByte(nil, "")
+	case next&SHORT_FILTER != 0 || next <= SHORT_LENGTH_MAX:
+		var v uint64
+		v, err = dec.ReadInteger(nil, "")
+		value = fmt.Sprint(v)
+	default:
+		value, err = dec.ReadString(nil, "")
+	}
+	if err != nil {
+		return err
+	}
+	dec.log = dec.log + fmt.Sprintf("Untyped parameter %s: %s\n", name, value)
+	if field, ok := untypedParameterFields[strings.ToLower(name)]; ok {
+		dec.setPduField(ctMember, field, value, setterString)
+		return nil
+	}
+	params := ctMember.FieldByName("Parameters")
+	if !params.IsValid() {
+		log.Println("Field Parameters not in decoding structure")
+		return nil
+	}
+	if params.IsNil() {
+		params.Set(reflect.MakeMap(params.Type()))
+	}
+	params.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(value))
+	return nil
+}
+
+// readVersionValue reads a Version-value, only the Short-integer form is
+// stored in hdr.
+//
+// Version-value = Short-integer | Text-string
+func (dec *MMSDecoder) readVersionValue(ctMember *reflect.Value, hdr string) error {
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
+	if dec.Data[dec.Offset+1]&SHORT_FILTER != 0 {
+		_, err := dec.ReadShortInteger(ctMember, hdr)
+		return err
+	}
+	v, err := dec.ReadString(nil, "")
+	dec.log = dec.log + fmt.Sprintf("%s as text: %s\n", hdr, v)
+	return err
+}
+
+// readFieldName reads a Field-name into hdr, well known names are stored as
+// their header name.
+//
+// Field-name = Token-text | Well-known-field-name
+func (dec *MMSDecoder) readFieldName(ctMember *reflect.Value, hdr string) error {
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
+	if dec.Data[dec.Offset+1]&SHORT_FILTER == 0 {
+		_, err := dec.ReadString(ctMember, hdr)
+		return err
+	}
+	code, err := dec.ReadShortInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	name, ok := headerNames[code]
+	if !ok {
+		name = fmt.Sprintf("%#x", code)
+	}
+	dec.setPduField(ctMember, hdr, name, setterString)
+	return nil
+}
+
+// readConstrainedMedia reads a media type into hdr
+//
+// Constrained-media = Extension-Media | Short-integer
+func (dec *MMSDecoder) readConstrainedMedia(ctMember *reflect.Value, hdr string) error {
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
+	if dec.Data[dec.Offset+1]&SHORT_FILTER == 0 {
+		_, err := dec.ReadString(ctMember, hdr)
+		return err
+	}
+	mt, err := dec.ReadShortInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	dec.setPduField(ctMember, hdr, contentTypeName(uint64(mt)), setterString)
+	return nil
+}
+
+// contentTypeName returns the well known content type assigned to code or the
+// code itself when it is not assigned
+func contentTypeName(code uint64) string {
+	if code < uint64(len(CONTENT_TYPES)) {
+		return CONTENT_TYPES[code]
+	}
+	return fmt.Sprintf("%#x", code)
+}
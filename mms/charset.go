@@ -0,0 +1,112 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mms
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// CHARSET_ENCODINGS holds the text encoding for every entry in CHARSETS,
+// us-ascii and utf-8 map to nil as no conversion is needed for them.
+var CHARSET_ENCODINGS map[string]encoding.Encoding = map[string]encoding.Encoding{
+	"big5":            traditionalchinese.Big5,
+	"iso-10646-ucs-2": unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"iso-8859-1":      charmap.ISO8859_1,
+	"iso-8859-2":      charmap.ISO8859_2,
+	"iso-8859-3":      charmap.ISO8859_3,
+	"iso-8859-4":      charmap.ISO8859_4,
+	"iso-8859-5":      charmap.ISO8859_5,
+	"iso-8859-6":      charmap.ISO8859_6,
+	"iso-8859-7":      charmap.ISO8859_7,
+	"iso-8859-8":      charmap.ISO8859_8,
+	"iso-8859-9":      charmap.ISO8859_9,
+	"shift_jis":       japanese.ShiftJIS,
+	"us-ascii":        nil,
+	"utf-8":           nil,
+}
+
+func getCharsetEncoding(charset string) (encoding.Encoding, error) {
+	if charset == "" || charset == "*" {
+		return nil, nil
+	}
+	e, ok := CHARSET_ENCODINGS[strings.ToLower(charset)]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownCharset, charset)
+	}
+	return e, nil
+}
+
+// getCharsetCode returns the MIBEnum value for charset as listed in CHARSETS
+func getCharsetCode(charset string) (uint64, bool) {
+	for k, v := range CHARSETS {
+		if strings.EqualFold(v, charset) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// DecodeCharset converts data encoded in charset to a UTF-8 string
+func DecodeCharset(charset string, data []byte) (string, error) {
+	e, err := getCharsetEncoding(charset)
+	if err != nil {
+		return "", err
+	} else if e == nil {
+		return string(data), nil
+	}
+	b, err := e.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode %s text: %s", charset, err)
+	}
+	return string(b), nil
+}
+
+// EncodeCharset converts the UTF-8 string s to charset
+func EncodeCharset(charset, s string) ([]byte, error) {
+	e, err := getCharsetEncoding(charset)
+	if err != nil {
+		return nil, err
+	} else if e == nil {
+		return []byte(s), nil
+	}
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode text as %s: %s", charset, err)
+	}
+	return b, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -22,19 +22,231 @@
 package mms
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
+	"time"
 )
 
-func NewDecoder(data []byte) *MMSDecoder {
-	return &MMSDecoder{Data: data}
+//NewDecoder creates a decoder for data, an optional DecoderLimits can be passed
+//to bound the resources used when decoding untrusted data.
+func NewDecoder(data []byte, limits ...DecoderLimits) *MMSDecoder {
+	dec := &MMSDecoder{Data: data}
+	if len(limits) > 0 {
+		dec.limits = limits[0]
+	}
+	return dec
 }
 
 type MMSDecoder struct {
-	Data   []byte
+	Data    []byte
+	Offset  int
+	log     string
+	limits  DecoderLimits
+	headers int
+	nesting int
+}
+
+//DecoderLimits bounds what the decoder accepts from a PDU, a zero value for
+//any of the limits means it is not enforced.
+type DecoderLimits struct {
+	// MaxParts is the maximum amount of parts in a multipart body.
+	MaxParts uint64
+	// MaxHeaders is the maximum amount of headers, including the ones
+	// from each part.
+	MaxHeaders int
+	// MaxStringLength is the maximum length of a text or encoded string.
+	MaxStringLength int
+	// MaxNesting is the maximum depth of multipart bodies.
+	MaxNesting int
+	// MaxSize is the maximum size of the data to decode.
+	MaxSize int
+}
+
+//DefaultDecoderLimits are the limits applied to PDUs received from the network.
+var DefaultDecoderLimits = DecoderLimits{
+	MaxParts:        100,
+	MaxHeaders:      1024,
+	MaxStringLength: 4096,
+	MaxNesting:      4,
+	MaxSize:         10 * 1024 * 1024,
+}
+
+// A uintvar is at most 5 octets long which is enough to hold 32 bits
+const maxUintVarLength = 5
+
+// Decoding failure causes, they are wrapped by DecodeError and can be checked
+// for with errors.Is.
+var (
+	ErrTruncated             = errors.New("message ended prematurely")
+	ErrUnknownCharset        = errors.New("unknown charset")
+	ErrUnexpectedMessageType = errors.New("unexpected message type")
+	ErrUnsupportedHeader     = errors.New("unsupported header")
+	ErrLimitExceeded         = errors.New("decoder limit exceeded")
+)
+
+var messageTypeNames = map[byte]string{
+	TYPE_SEND_REQ:         "m-send.req",
+	TYPE_SEND_CONF:        "m-send.conf",
+	TYPE_NOTIFICATION_IND: "m-notification.ind",
+	TYPE_NOTIFYRESP_IND:   "m-notifyresp.ind",
+	TYPE_RETRIEVE_CONF:    "m-retrieve.conf",
+	TYPE_ACKNOWLEDGE_IND:  "m-acknowledge.ind",
+	TYPE_DELIVERY_IND:     "m-delivery.ind",
+	TYPE_READ_REC_IND:     "m-read-rec.ind",
+	TYPE_READ_ORIG_IND:    "m-read-orig.ind",
+	TYPE_FORWARD_REQ:      "m-forward.req",
+	TYPE_FORWARD_CONF:     "m-forward.conf",
+	TYPE_MBOX_STORE_REQ:   "m-mbox-store.req",
+	TYPE_MBOX_STORE_CONF:  "m-mbox-store.conf",
+	TYPE_MBOX_VIEW_REQ:    "m-mbox-view.req",
+	TYPE_MBOX_VIEW_CONF:   "m-mbox-view.conf",
+	TYPE_MBOX_UPLOAD_REQ:  "m-mbox-upload.req",
+	TYPE_MBOX_UPLOAD_CONF: "m-mbox-upload.conf",
+	TYPE_MBOX_DELETE_REQ:  "m-mbox-delete.req",
+	TYPE_MBOX_DELETE_CONF: "m-mbox-delete.conf",
+	TYPE_MBOX_DESCR:       "m-mbox-descr",
+	TYPE_CANCEL_REQ:       "m-cancel.req",
+	TYPE_CANCEL_CONF:      "m-cancel.conf",
+}
+
+var headerNames = map[byte]string{
+	BCC:                           "Bcc",
+	CC:                            "Cc",
+	X_MMS_CONTENT_LOCATION:        "X-Mms-Content-Location",
+	CONTENT_TYPE:                  "Content-Type",
+	DATE:                          "Date",
+	X_MMS_DELIVERY_REPORT:         "X-Mms-Delivery-Report",
+	X_MMS_DELIVERY_TIME:           "X-Mms-Delivery-Time",
+	X_MMS_EXPIRY:                  "X-Mms-Expiry",
+	FROM:                          "From",
+	X_MMS_MESSAGE_CLASS:           "X-Mms-Message-Class",
+	MESSAGE_ID:                    "Message-ID",
+	X_MMS_MESSAGE_TYPE:            "X-Mms-Message-Type",
+	X_MMS_MMS_VERSION:             "X-Mms-MMS-Version",
+	X_MMS_MESSAGE_SIZE:            "X-Mms-Message-Size",
+	X_MMS_PRIORITY:                "X-Mms-Priority",
+	X_MMS_READ_REPORT:             "X-Mms-Read-Report",
+	X_MMS_REPORT_ALLOWED:          "X-Mms-Report-Allowed",
+	X_MMS_RESPONSE_STATUS:         "X-Mms-Response-Status",
+	X_MMS_RESPONSE_TEXT:           "X-Mms-Response-Text",
+	X_MMS_SENDER_VISIBILITY:       "X-Mms-Sender-Visibility",
+	X_MMS_STATUS:                  "X-Mms-Status",
+	SUBJECT:                       "Subject",
+	TO:                            "To",
+	X_MMS_TRANSACTION_ID:          "X-Mms-Transaction-Id",
+	X_MMS_RETRIEVE_STATUS:         "X-Mms-Retrieve-Status",
+	X_MMS_RETRIEVE_TEXT:           "X-Mms-Retrieve-Text",
+	X_MMS_READ_STATUS:             "X-Mms-Read-Status",
+	X_MMS_REPLY_CHARGING:          "X-Mms-Reply-Charging",
+	X_MMS_REPLY_CHARGING_DEADLINE: "X-Mms-Reply-Charging-Deadline",
+	X_MMS_REPLY_CHARGING_ID:       "X-Mms-Reply-Charging-ID",
+	X_MMS_REPLY_CHARGING_SIZE:     "X-Mms-Reply-Charging-Size",
+	X_MMS_PREVIOUSLY_SENT_BY:      "X-Mms-Previously-Sent-By",
+	X_MMS_PREVIOUSLY_SENT_DATE:    "X-Mms-Previously-Sent-Date",
+	X_MMS_STORE:                   "X-Mms-Store",
+	X_MMS_MM_STATE:                "X-Mms-MM-State",
+	X_MMS_MM_FLAGS:                "X-Mms-MM-Flags",
+	X_MMS_STORE_STATUS:            "X-Mms-Store-Status",
+	X_MMS_STORE_STATUS_TEXT:       "X-Mms-Store-Status-Text",
+	X_MMS_STORED:                  "X-Mms-Stored",
+	X_MMS_ATTRIBUTES:              "X-Mms-Attributes",
+	X_MMS_TOTALS:                  "X-Mms-Totals",
+	X_MMS_MBOX_TOTALS:             "X-Mms-Mbox-Totals",
+	X_MMS_QUOTAS:                  "X-Mms-Quotas",
+	X_MMS_MBOX_QUOTAS:             "X-Mms-Mbox-Quotas",
+	X_MMS_MESSAGE_COUNT:           "X-Mms-Message-Count",
+	X_MMS_START:                   "X-Mms-Start",
+	X_MMS_LIMIT:                   "X-Mms-Limit",
+	X_MMS_APPLIC_ID:               "X-Mms-Applic-ID",
+	X_MMS_REPLY_APPLIC_ID:         "X-Mms-Reply-Applic-ID",
+	X_MMS_AUX_APPLIC_INFO:         "X-Mms-Aux-Applic-Info",
+	X_MMS_CONTENT_CLASS:           "X-Mms-Content-Class",
+	X_MMS_DRM_CONTENT:             "X-Mms-DRM-Content",
+	X_MMS_ADAPTATION_ALLOWED:      "X-Mms-Adaptation-Allowed",
+	X_MMS_CANCEL_ID:               "X-Mms-Cancel-ID",
+	X_MMS_CANCEL_STATUS:           "X-Mms-Cancel-Status",
+}
+
+//DecodeError is returned by Decode and tells which PDU and header were being
+//decoded and where in the data the failure happened, Err holds the cause.
+type DecodeError struct {
+	// Type is the message type of the PDU being decoded.
+	Type byte
+	// Header is the field being decoded, 0 if the failure happened
+	// while reading an application header.
+	Header byte
+	// Offset is the position in the data where decoding stopped.
 	Offset int
-	log    string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	pduName, ok := messageTypeNames[e.Type]
+	if !ok {
+		pduName = fmt.Sprintf("message type %#x", e.Type)
+	}
+	hdrName, ok := headerNames[e.Header]
+	if !ok {
+		hdrName = fmt.Sprintf("%#x", e.Header)
+	}
+	return fmt.Sprintf("cannot decode %s header %s @%d: %s", pduName, hdrName, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//HeaderName returns the field name of the header that failed to decode.
+func (e *DecodeError) HeaderName() string {
+	return headerNames[e.Header]
+}
+
+//TruncatedError is returned by the read functions when the data ends before
+//the value being decoded is complete, it matches ErrTruncated.
+type TruncatedError struct {
+	// Offset is the position of the last byte successfully consumed.
+	Offset int
+	// Needed is the amount of bytes required after Offset.
+	Needed int
+	// Length is the length of the data being decoded.
+	Length int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s, %d bytes needed after offset %d and payload length is %d",
+		ErrTruncated, e.Needed, e.Offset, e.Length)
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+//countHeader accounts for a decoded header against the MaxHeaders limit.
+func (dec *MMSDecoder) countHeader() error {
+	dec.headers++
+	if dec.limits.MaxHeaders > 0 && dec.headers > dec.limits.MaxHeaders {
+		return fmt.Errorf("%w, more than %d headers", ErrLimitExceeded, dec.limits.MaxHeaders)
+	}
+	return nil
+}
+
+//checkStringLength verifies length against the MaxStringLength limit.
+func (dec *MMSDecoder) checkStringLength(length int) error {
+	if dec.limits.MaxStringLength > 0 && length > dec.limits.MaxStringLength {
+		return fmt.Errorf("%w, string @%d is longer than %d", ErrLimitExceeded, dec.Offset, dec.limits.MaxStringLength)
+	}
+	return nil
+}
+
+//checkAvailable makes sure n bytes can be read after the current offset.
+func (dec *MMSDecoder) checkAvailable(n int) error {
+	if n < 0 || dec.Offset < -1 || dec.Offset+n >= len(dec.Data) {
+		return &TruncatedError{Offset: dec.Offset, Needed: n, Length: len(dec.Data)}
+	}
+	return nil
 }
 
 func (dec *MMSDecoder) setPduField(pdu *reflect.Value, name string, v interface{},
@@ -42,15 +254,34 @@ func (dec *MMSDecoder) setPduField(pdu *reflect.Value, name string, v interface{
 
 	if name != "" {
 		field := pdu.FieldByName(name)
-		if field.IsValid() {
+		if !field.IsValid() {
+			log.Println("Field", name, "not in decoding structure")
+		} else if !canHold(field, v) {
+			log.Printf("Field %s cannot hold a %T value", name, v)
+		} else {
 			setter(&field, v)
 			dec.log = dec.log + fmt.Sprintf("Setting %s to %s\n", name, v)
-		} else {
-			log.Println("Field", name, "not in decoding structure")
 		}
 	}
 }
 
+//canHold tells if v can be stored in field by the setters, a mismatch can only
+//be caused by a malformed message and must not panic.
+func canHold(field reflect.Value, v interface{}) bool {
+	switch v.(type) {
+	case string:
+		return field.Kind() == reflect.String
+	case uint64:
+		switch field.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+	case []byte:
+		return field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
 func setterString(field *reflect.Value, v interface{}) { field.SetString(v.(string)) }
 func setterUint64(field *reflect.Value, v interface{}) { field.SetUint(v.(uint64)) }
 func setterSlice(field *reflect.Value, v interface{})  { field.SetBytes(v.([]byte)) }
@@ -58,6 +289,9 @@ func setterSlice(field *reflect.Value, v interface{})  { field.SetBytes(v.([]byt
 func (dec *MMSDecoder) ReadEncodedString(reflectedPdu *reflect.Value, hdr string) (string, error) {
 	var length uint64
 	var err error
+	if err = dec.checkAvailable(1); err != nil {
+		return "", err
+	}
 	switch {
 	case dec.Data[dec.Offset+1] < SHORT_LENGTH_MAX:
 		var l byte
@@ -70,17 +304,48 @@ func (dec *MMSDecoder) ReadEncodedString(reflectedPdu *reflect.Value, hdr string
 	if err != nil {
 		return "", err
 	}
-	if length != 0 {
-		charset, err := dec.ReadCharset(nil, "")
-		if err != nil {
-			return "", err
-		}
-		dec.log = dec.log + fmt.Sprintf("Next string encoded with: %s\n", charset)
+	if length == 0 {
+		return dec.ReadString(reflectedPdu, hdr)
 	}
-	var str string
-	if str, err = dec.ReadString(reflectedPdu, hdr); err != nil {
+	if length > uint64(len(dec.Data)) {
+		return "", &TruncatedError{Offset: dec.Offset, Needed: int(length), Length: len(dec.Data)}
+	}
+	if err := dec.checkStringLength(int(length)); err != nil {
 		return "", err
 	}
+
+	end := dec.Offset + int(length)
+	if end >= len(dec.Data) {
+		return "", fmt.Errorf("encoded string length %d @%d exceeds data", length, dec.Offset)
+	}
+	charset, err := dec.ReadCharset(nil, "")
+	if err != nil {
+		return "", err
+	}
+	if dec.Offset > end {
+		return "", fmt.Errorf("encoded string charset @%d exceeds length %d", dec.Offset, length)
+	}
+	dec.log = dec.log + fmt.Sprintf("Next string encoded with: %s\n", charset)
+	text := dec.Data[dec.Offset+1 : end+1]
+	if len(text) > 0 && text[0] == TEXT_QUOTE {
+		text = text[1:]
+	}
+	// drop the End-of-string, some implementations use a two octet
+	// terminator for UCS-2
+	if len(text) > 0 && text[len(text)-1] == 0 {
+		text = text[:len(text)-1]
+	}
+	if strings.EqualFold(charset, "iso-10646-ucs-2") && len(text)%2 != 0 && text[len(text)-1] == 0 {
+		text = text[:len(text)-1]
+	}
+	dec.Offset = end
+
+	str, err := DecodeCharset(charset, text)
+	if err != nil {
+		dec.log = dec.log + fmt.Sprintf("Keeping raw string: %s\n", err)
+		str = string(text)
+	}
+	dec.setPduField(reflectedPdu, hdr, str, setterString)
 	return str, nil
 }
 
@@ -108,6 +373,9 @@ func (dec *MMSDecoder) ReadQ(reflectedPdu *reflect.Value) error {
 // Length-quote = <Octet 31>
 // Length = Uintvar-integer
 func (dec *MMSDecoder) ReadLength(reflectedPdu *reflect.Value) (length uint64, err error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return 0, err
+	}
 	switch {
 	case dec.Data[dec.Offset+1]&0x7f <= SHORT_LENGTH_MAX:
 		l, err := dec.ReadShortInteger(nil, "")
@@ -130,7 +398,10 @@ func (dec *MMSDecoder) ReadLength(reflectedPdu *reflect.Value) (length uint64, e
 func (dec *MMSDecoder) ReadCharset(reflectedPdu *reflect.Value, hdr string) (string, error) {
 	var charset string
 
-	if dec.Data[dec.Offset] == ANY_CHARSET {
+	if err := dec.checkAvailable(1); err != nil {
+		return "", err
+	}
+	if dec.Data[dec.Offset+1] == ANY_CHARSET {
 		dec.Offset++
 		charset = "*"
 	} else {
@@ -140,63 +411,222 @@ func (dec *MMSDecoder) ReadCharset(reflectedPdu *reflect.Value, hdr string) (str
 		}
 		var ok bool
 		if charset, ok = CHARSETS[charCode]; !ok {
-			return "", fmt.Errorf("Cannot find matching charset for %#x == %d", charCode, charCode)
+			return "", fmt.Errorf("%w %#x == %d", ErrUnknownCharset, charCode, charCode)
 		}
 	}
 	if hdr != "" {
-		reflectedPdu.FieldByName("Charset").SetString(charset)
+		dec.setPduField(reflectedPdu, "Charset", charset, setterString)
 	}
 	return charset, nil
 }
 
+// ReadMediaType reads a media type without parameters, a content type with
+// parameters is read with ReadAttachment.
+//
+// Constrained-media = Constrained-encoding
 func (dec *MMSDecoder) ReadMediaType(reflectedPdu *reflect.Value, hdr string) (err error) {
 	var mediaType string
-	var endOffset int
 	origOffset := dec.Offset
 
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
 	if dec.Data[dec.Offset+1] <= SHORT_LENGTH_MAX || dec.Data[dec.Offset+1] == LENGTH_QUOTE {
-		if length, err := dec.ReadLength(nil); err != nil {
-			return err
-		} else {
-			endOffset = int(length) + dec.Offset
-		}
+		return fmt.Errorf("media type @%d has parameters, it has to be read as a content type", origOffset+1)
 	}
 
 	if dec.Data[dec.Offset+1] >= TEXT_MIN && dec.Data[dec.Offset+1] <= TEXT_MAX {
 		if mediaType, err = dec.ReadString(nil, ""); err != nil {
 			return err
 		}
-	} else if mt, err := dec.ReadInteger(nil, ""); err == nil && len(CONTENT_TYPES) > int(mt) {
+	} else if mt, err := dec.ReadInteger(nil, ""); err == nil && mt < uint64(len(CONTENT_TYPES)) {
 		mediaType = CONTENT_TYPES[mt]
+	} else if _, ok := err.(*TruncatedError); ok {
+		return err
 	} else {
-		return fmt.Errorf("cannot decode media type for field beginning with %#x@%d", dec.Data[origOffset], origOffset)
+		return fmt.Errorf("cannot decode media type for field beginning with %#x@%d", dec.Data[origOffset+1], origOffset+1)
 	}
 
-	// skip the rest of the content type params
-	if endOffset > 0 {
-		dec.Offset = endOffset
-	}
-
-	reflectedPdu.FieldByName(hdr).SetString(mediaType)
+	dec.setPduField(reflectedPdu, hdr, mediaType, setterString)
 	dec.log = dec.log + fmt.Sprintf("%s: %s\n", hdr, mediaType)
 
 	return nil
 }
 
 func (dec *MMSDecoder) ReadTo(reflectedPdu *reflect.Value) error {
+	return dec.ReadAddress(reflectedPdu, "To")
+}
+
+//ReadAddress reads an address header such as To, Cc or Bcc and appends it to
+//the hdr list as these headers can occur more than once.
+func (dec *MMSDecoder) ReadAddress(reflectedPdu *reflect.Value, hdr string) error {
 	// field in the MMS protocol
-	toField, err := dec.ReadEncodedString(reflectedPdu, "")
+	address, err := dec.ReadEncodedString(reflectedPdu, "")
 	if err != nil {
 		return err
 	}
 	// field in the golang structure
-	to := reflectedPdu.FieldByName("To")
-	toSlice := reflect.Append(to, reflect.ValueOf(toField))
-	reflectedPdu.FieldByName("To").Set(toSlice)
-	return err
+	dec.appendPduField(reflectedPdu, hdr, address)
+	return nil
+}
+
+// ReadPreviouslySentBy reads a X-Mms-Previously-Sent-By value
+//
+// Previously-sent-by-value = Value-length Forwarded-count-value Encoded-string-value
+func (dec *MMSDecoder) ReadPreviouslySentBy(reflectedPdu *reflect.Value) error {
+	length, err := dec.ReadLength(nil)
+	if err != nil {
+		return err
+	}
+	endOffset := dec.Offset + int(length)
+	count, err := dec.ReadInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	sentBy, err := dec.ReadEncodedString(nil, "")
+	if err != nil {
+		return err
+	}
+	if dec.Offset != endOffset {
+		return fmt.Errorf("Previously-Sent-By length is %d but expected size is %d",
+			int(length)-(endOffset-dec.Offset), length)
+	}
+	dec.appendPduField(reflectedPdu, "PreviouslySentBy", SentBy{Count: count, Address: sentBy})
+	return nil
+}
+
+// ReadPreviouslySentDate reads a X-Mms-Previously-Sent-Date value
+//
+// Previously-sent-date-value = Value-length Forwarded-count-value Date-value
+func (dec *MMSDecoder) ReadPreviouslySentDate(reflectedPdu *reflect.Value) error {
+	length, err := dec.ReadLength(nil)
+	if err != nil {
+		return err
+	}
+	endOffset := dec.Offset + int(length)
+	count, err := dec.ReadInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	sentDate, err := dec.ReadLongInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	if dec.Offset != endOffset {
+		return fmt.Errorf("Previously-Sent-Date length is %d but expected size is %d",
+			int(length)-(endOffset-dec.Offset), length)
+	}
+	dec.appendPduField(reflectedPdu, "PreviouslySentDate", SentDate{Count: count, Date: sentDate})
+	return nil
+}
+
+// ReadMMFlags reads a X-Mms-MM-Flags value and appends it to MMFlags
+//
+// MM-flags-value = Value-length ( Add-token | Remove-token | Filter-token ) Encoded-string-value
+func (dec *MMSDecoder) ReadMMFlags(reflectedPdu *reflect.Value) error {
+	length, err := dec.ReadLength(nil)
+	if err != nil {
+		return err
+	}
+	endOffset := dec.Offset + int(length)
+	token, err := dec.ReadByte(nil, "")
+	if err != nil {
+		return err
+	}
+	keyword, err := dec.ReadEncodedString(nil, "")
+	if err != nil {
+		return err
+	}
+	if dec.Offset != endOffset {
+		return fmt.Errorf("MM-Flags length is %d but expected size is %d",
+			int(length)-(endOffset-dec.Offset), length)
+	}
+	dec.appendPduField(reflectedPdu, "MMFlags", MMFlag{Token: token, Keyword: keyword})
+	return nil
+}
+
+// ReadMboxCount reads a X-Mms-Mbox-Totals or X-Mms-Mbox-Quotas value and
+// appends it to hdr
+//
+// Mbox-totals-value = Value-length ( Message-total-token | Size-total-token ) Integer-Value
+func (dec *MMSDecoder) ReadMboxCount(reflectedPdu *reflect.Value, hdr string) error {
+	length, err := dec.ReadLength(nil)
+	if err != nil {
+		return err
+	}
+	endOffset := dec.Offset + int(length)
+	token, err := dec.ReadByte(nil, "")
+	if err != nil {
+		return err
+	}
+	v, err := dec.ReadInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	if dec.Offset != endOffset {
+		return fmt.Errorf("%s length is %d but expected size is %d",
+			hdr, int(length)-(endOffset-dec.Offset), length)
+	}
+	dec.appendPduField(reflectedPdu, hdr, MboxCount{Token: token, Value: v})
+	return nil
+}
+
+// ReadStatusCountValue reads a value with read and appends it to hdr, in a
+// m-mbox-delete.conf the value can be preceded by the index of the message
+// it refers to, which is skipped.
+//
+// Value-length Status-count-value ( Content-location-value | Response-status-value | Text-value )
+func (dec *MMSDecoder) ReadStatusCountValue(reflectedPdu *reflect.Value, hdr string, read func() (interface{}, error)) error {
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
+	endOffset := -1
+	if dec.Data[dec.Offset+1] <= LENGTH_QUOTE {
+		length, err := dec.ReadLength(nil)
+		if err != nil {
+			return err
+		}
+		endOffset = dec.Offset + int(length)
+		if _, err := dec.ReadInteger(nil, ""); err != nil {
+			return err
+		}
+	}
+	v, err := read()
+	if err != nil {
+		return err
+	}
+	if endOffset != -1 && dec.Offset != endOffset {
+		return fmt.Errorf("%s length does not match the status count value", hdr)
+	}
+	dec.appendPduField(reflectedPdu, hdr, v)
+	return nil
+}
+
+//isSliceField tells if the name field of pdu holds a list of values, such as
+//the headers which can occur more than once in m-mbox PDUs.
+func isSliceField(pdu *reflect.Value, name string) bool {
+	field := pdu.FieldByName(name)
+	return field.IsValid() && field.Kind() == reflect.Slice
+}
+
+func (dec *MMSDecoder) appendPduField(pdu *reflect.Value, name string, v interface{}) {
+	field := pdu.FieldByName(name)
+	if !field.IsValid() {
+		log.Println("Field", name, "not in decoding structure")
+		return
+	}
+	if field.Kind() != reflect.Slice || !reflect.TypeOf(v).AssignableTo(field.Type().Elem()) {
+		log.Printf("Field %s cannot hold a %T value", name, v)
+		return
+	}
+	field.Set(reflect.Append(field, reflect.ValueOf(v)))
+	dec.log = dec.log + fmt.Sprintf("Appending %v to %s\n", v, name)
 }
 
 func (dec *MMSDecoder) ReadString(reflectedPdu *reflect.Value, hdr string) (string, error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return "", err
+	}
 	dec.Offset++
 	if dec.Data[dec.Offset] == 34 { // Skip the quote char(34) == "
 		dec.Offset++
@@ -206,9 +636,12 @@ func (dec *MMSDecoder) ReadString(reflectedPdu *reflect.Value, hdr string) (stri
 		if dec.Data[dec.Offset] == 0 {
 			break
 		}
+		if err := dec.checkStringLength(dec.Offset - begin + 1); err != nil {
+			return "", err
+		}
 	}
 	if len(dec.Data) == dec.Offset {
-		return "", fmt.Errorf("reached end of data while trying to read string: %s", dec.Data[begin:])
+		return "", fmt.Errorf("%w, reached end of data while trying to read string: %s", ErrTruncated, dec.Data[begin:])
 	}
 	v := string(dec.Data[begin:dec.Offset])
 	dec.setPduField(reflectedPdu, hdr, v, setterString)
@@ -217,6 +650,9 @@ func (dec *MMSDecoder) ReadString(reflectedPdu *reflect.Value, hdr string) (stri
 }
 
 func (dec *MMSDecoder) ReadShortInteger(reflectedPdu *reflect.Value, hdr string) (byte, error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return 0, err
+	}
 	dec.Offset++
 	/*
 		TODO fix use of short when not short
@@ -231,6 +667,9 @@ func (dec *MMSDecoder) ReadShortInteger(reflectedPdu *reflect.Value, hdr string)
 }
 
 func (dec *MMSDecoder) ReadByte(reflectedPdu *reflect.Value, hdr string) (byte, error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return 0, err
+	}
 	dec.Offset++
 	v := dec.Data[dec.Offset]
 	dec.setPduField(reflectedPdu, hdr, uint64(v), setterUint64)
@@ -239,6 +678,12 @@ func (dec *MMSDecoder) ReadByte(reflectedPdu *reflect.Value, hdr string) (byte,
 }
 
 func (dec *MMSDecoder) ReadBoundedBytes(reflectedPdu *reflect.Value, hdr string, end int) ([]byte, error) {
+	if end > len(dec.Data) {
+		return nil, &TruncatedError{Offset: dec.Offset - 1, Needed: end - dec.Offset, Length: len(dec.Data)}
+	}
+	if dec.Offset < 0 || end < dec.Offset {
+		return nil, fmt.Errorf("cannot read bytes from %d up to %d", dec.Offset, end)
+	}
 	v := []byte(dec.Data[dec.Offset:end])
 	dec.setPduField(reflectedPdu, hdr, v, setterSlice)
 	dec.Offset = end - 1
@@ -250,10 +695,19 @@ func (dec *MMSDecoder) ReadBoundedBytes(reflectedPdu *reflect.Value, hdr string,
 // more octects available are indicated with the most significant bit
 // set to 1
 func (dec *MMSDecoder) ReadUintVar(reflectedPdu *reflect.Value, hdr string) (value uint64, err error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return 0, err
+	}
 	dec.Offset++
-	for dec.Data[dec.Offset]>>7 == 0x01 {
+	for octets := 1; dec.Data[dec.Offset]>>7 == 0x01; octets++ {
+		if octets == maxUintVarLength {
+			return 0, fmt.Errorf("uintvar @%d is longer than %d octets", dec.Offset, maxUintVarLength)
+		}
 		value = value << 7
 		value |= uint64(dec.Data[dec.Offset] & 0x7F)
+		if err := dec.checkAvailable(1); err != nil {
+			return 0, err
+		}
 		dec.Offset++
 	}
 
@@ -265,6 +719,9 @@ func (dec *MMSDecoder) ReadUintVar(reflectedPdu *reflect.Value, hdr string) (val
 }
 
 func (dec *MMSDecoder) ReadInteger(reflectedPdu *reflect.Value, hdr string) (uint64, error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return 0, err
+	}
 	param := dec.Data[dec.Offset+1]
 	var v uint64
 	var err error
@@ -276,17 +733,58 @@ func (dec *MMSDecoder) ReadInteger(reflectedPdu *reflect.Value, hdr string) (uin
 	default:
 		v, err = dec.ReadLongInteger(nil, "")
 	}
+	if err != nil {
+		return 0, err
+	}
 	dec.setPduField(reflectedPdu, hdr, v, setterUint64)
 
 	return v, err
 }
 
+//ReadExpiry reads an X-Mms-Expiry value and stores it in hdr as an absolute
+//date, relative expiries are resolved against the current time.
+func (dec *MMSDecoder) ReadExpiry(reflectedPdu *reflect.Value, hdr string) error {
+	size, err := dec.ReadLength(nil)
+	if err != nil {
+		return err
+	}
+	end := dec.Offset + int(size)
+	if end >= len(dec.Data) {
+		return fmt.Errorf("expiry length %d @%d exceeds data", size, dec.Offset)
+	}
+	token, err := dec.ReadByte(nil, "")
+	if err != nil {
+		return err
+	}
+	v, err := dec.ReadInteger(nil, "")
+	if err != nil {
+		return err
+	}
+	switch token {
+	case ExpiryTokenAbsolute:
+	case ExpiryTokenRelative:
+		v += uint64(time.Now().Unix())
+	default:
+		return fmt.Errorf("unhandled expiry token %#x", token)
+	}
+	dec.Offset = end
+	dec.log = dec.log + fmt.Sprintf("Message Expiry %d with token %#x\n", v, token)
+	dec.setPduField(reflectedPdu, hdr, v, setterUint64)
+	return nil
+}
+
 func (dec *MMSDecoder) ReadLongInteger(reflectedPdu *reflect.Value, hdr string) (uint64, error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return 0, err
+	}
 	dec.Offset++
 	size := int(dec.Data[dec.Offset])
 	if size > SHORT_LENGTH_MAX {
 		return 0, fmt.Errorf("cannot encode long integer, lenght was %d but expected %d", size, SHORT_LENGTH_MAX)
 	}
+	if err := dec.checkAvailable(size); err != nil {
+		return 0, err
+	}
 	dec.Offset++
 	end := dec.Offset + size
 	var v uint64
@@ -301,9 +799,9 @@ func (dec *MMSDecoder) ReadLongInteger(reflectedPdu *reflect.Value, hdr string)
 }
 
 //getParam reads the next parameter to decode and returns it if it's well known
-//or just decodes and discards if it's application specific, if the latter is
-//the case it also returns false
-func (dec *MMSDecoder) getParam() (byte, bool, error) {
+//or decodes and keeps it in the UnknownHeaders of the PDU if it's application
+//specific, if the latter is the case it also returns false
+func (dec *MMSDecoder) getParam(reflectedPdu *reflect.Value) (byte, bool, error) {
 	if dec.Data[dec.Offset]&0x80 != 0 {
 		return dec.Data[dec.Offset] & 0x7f, true, nil
 	} else {
@@ -318,12 +816,16 @@ func (dec *MMSDecoder) getParam() (byte, bool, error) {
 		if value, err = dec.ReadString(nil, ""); err != nil {
 			return 0, false, err
 		}
-		dec.log = dec.log + fmt.Sprintf("Ignoring application header: %#x: %s", param, value)
+		dec.log = dec.log + fmt.Sprintf("Keeping application header %s: %s\n", param, value)
+		dec.appendPduField(reflectedPdu, "UnknownHeaders", RawHeader{Name: param, Value: []byte(value)})
 		return 0, false, nil
 	}
 }
 
 func (dec *MMSDecoder) skipFieldValue() error {
+	if err := dec.checkAvailable(1); err != nil {
+		return err
+	}
 	switch {
 	case dec.Data[dec.Offset+1] < LENGTH_QUOTE:
 		l, err := dec.ReadByte(nil, "")
@@ -331,23 +833,19 @@ func (dec *MMSDecoder) skipFieldValue() error {
 			return err
 		}
 		length := int(l)
-		if dec.Offset+length >= len(dec.Data) {
+		if length < 0 || dec.Offset+length >= len(dec.Data) {
 			return fmt.Errorf("Bad field value length")
 		}
 		dec.Offset += length
 		return nil
 	case dec.Data[dec.Offset+1] == LENGTH_QUOTE:
 		dec.Offset++
-		// TODO These tests should be done in basic read functions
-		if dec.Offset+1 >= len(dec.Data) {
-			return fmt.Errorf("Bad uintvar")
-		}
 		l, err := dec.ReadUintVar(nil, "")
 		if err != nil {
 			return err
 		}
 		length := int(l)
-		if dec.Offset+length >= len(dec.Data) {
+		if length < 0 || dec.Offset+length >= len(dec.Data) {
 			return fmt.Errorf("Bad field value length")
 		}
 		dec.Offset += length
@@ -361,14 +859,27 @@ func (dec *MMSDecoder) skipFieldValue() error {
 	return err
 }
 
+//Decode decodes the data into pdu, failures are returned as a *DecodeError.
 func (dec *MMSDecoder) Decode(pdu MMSReader) (err error) {
 	reflectedPdu := reflect.ValueOf(pdu).Elem()
 	moreHdrToRead := true
+	var param byte
+	defer func() {
+		if err != nil {
+			err = dec.decodeError(&reflectedPdu, param, err)
+		}
+	}()
+	if dec.limits.MaxSize > 0 && len(dec.Data) > dec.limits.MaxSize {
+		return fmt.Errorf("%w, data size %d is larger than %d", ErrLimitExceeded, len(dec.Data), dec.limits.MaxSize)
+	}
 	//fmt.Printf("len data: %d, data: %x\n", len(dec.Data), dec.Data)
 	for ; (dec.Offset < len(dec.Data)) && moreHdrToRead; dec.Offset++ {
 		//fmt.Printf("offset %d, value: %x\n", dec.Offset, dec.Data[dec.Offset])
-		err = nil
-		param, needsDecoding, err := dec.getParam()
+		var needsDecoding bool
+		if err = dec.countHeader(); err != nil {
+			return err
+		}
+		param, needsDecoding, err = dec.getParam(&reflectedPdu)
 		if err != nil {
 			return err
 		} else if !needsDecoding {
@@ -376,51 +887,57 @@ func (dec *MMSDecoder) Decode(pdu MMSReader) (err error) {
 		}
 		switch param {
 		case X_MMS_MESSAGE_TYPE:
-			dec.Offset++
 			expectedType := byte(reflectedPdu.FieldByName("Type").Uint())
-			parsedType := dec.Data[dec.Offset]
+			var parsedType byte
+			if parsedType, err = dec.ReadByte(nil, ""); err != nil {
+				return err
+			}
 			//Unknown message types will be discarded. OMA-WAP-MMS-ENC-v1.1 section 7.2.16
 			if parsedType != expectedType {
-				err = fmt.Errorf("Expected message type %x got %x", expectedType, parsedType)
+				err = fmt.Errorf("%w, expected %x got %x", ErrUnexpectedMessageType, expectedType, parsedType)
 			}
 		case FROM:
-			dec.Offset++
-			size := int(dec.Data[dec.Offset])
+			var size uint64
+			if size, err = dec.ReadLength(nil); err != nil {
+				return err
+			}
 			valStart := dec.Offset
-			dec.Offset++
-			token := dec.Data[dec.Offset]
+			var token byte
+			if token, err = dec.ReadByte(nil, ""); err != nil {
+				return err
+			}
 			switch token {
 			case TOKEN_INSERT_ADDRESS:
 				break
 			case TOKEN_ADDRESS_PRESENT:
-				// TODO add check for /TYPE=PLMN
-				_, err = dec.ReadEncodedString(&reflectedPdu, "From")
-				if valStart+size != dec.Offset {
+				var from string
+				if from, err = dec.ReadEncodedString(nil, ""); err != nil {
+					return err
+				}
+				if valStart+int(size) != dec.Offset {
 					err = fmt.Errorf("From field length is %d but expected size is %d",
 						dec.Offset-valStart, size)
 				}
+				// addresses are stored in their canonical encoding when they
+				// can be parsed, such as /TYPE=plmn becoming /TYPE=PLMN
+				if address, perr := ParseAddress(from); perr == nil {
+					from = address.String()
+				}
+				dec.setPduField(&reflectedPdu, "From", from, setterString)
 			default:
 				err = fmt.Errorf("Unhandled token address in from field %x", token)
 			}
 		case X_MMS_EXPIRY:
-			dec.Offset++
-			size := int(dec.Data[dec.Offset])
-			dec.Offset++
-			token := dec.Data[dec.Offset]
-			dec.Offset++
-			var val uint
-			endOffset := dec.Offset + size - 2
-			for ; dec.Offset < endOffset; dec.Offset++ {
-				val = (val << 8) | uint(dec.Data[dec.Offset])
-			}
-			// TODO add switch case for token
-			dec.log = dec.log + fmt.Sprintf("Expiry token: %x\n", token)
-			reflectedPdu.FieldByName("Expiry").SetUint(uint64(val))
-			dec.log = dec.log + fmt.Sprintf("Message Expiry %d, %x\n", val, dec.Data[dec.Offset])
+			err = dec.ReadExpiry(&reflectedPdu, "Expiry")
 		case X_MMS_TRANSACTION_ID:
 			_, err = dec.ReadString(&reflectedPdu, "TransactionId")
 		case CONTENT_TYPE:
 			ctMember := reflectedPdu.FieldByName("Content")
+			if !ctMember.IsValid() {
+				err = dec.readOutgoingContent(&reflectedPdu)
+				moreHdrToRead = false
+				break
+			}
 			if err = dec.ReadAttachment(&ctMember); err != nil {
 				return err
 			}
@@ -433,8 +950,13 @@ func (dec *MMSDecoder) Decode(pdu MMSReader) (err error) {
 			}
 			moreHdrToRead = false
 		case X_MMS_CONTENT_LOCATION:
+			if isSliceField(&reflectedPdu, "ContentLocation") {
+				err = dec.ReadStatusCountValue(&reflectedPdu, "ContentLocation", func() (interface{}, error) {
+					return dec.ReadString(nil, "")
+				})
+				break
+			}
 			_, err = dec.ReadString(&reflectedPdu, "ContentLocation")
-			moreHdrToRead = false
 		case MESSAGE_ID:
 			_, err = dec.ReadString(&reflectedPdu, "MessageId")
 		case SUBJECT:
@@ -442,7 +964,9 @@ func (dec *MMSDecoder) Decode(pdu MMSReader) (err error) {
 		case TO:
 			err = dec.ReadTo(&reflectedPdu)
 		case CC:
-			_, err = dec.ReadEncodedString(&reflectedPdu, "Cc")
+			err = dec.ReadAddress(&reflectedPdu, "Cc")
+		case BCC:
+			err = dec.ReadAddress(&reflectedPdu, "Bcc")
 		case X_MMS_REPLY_CHARGING_ID:
 			_, err = dec.ReadString(&reflectedPdu, "ReplyChargingId")
 		case X_MMS_RETRIEVE_TEXT:
@@ -465,21 +989,101 @@ func (dec *MMSDecoder) Decode(pdu MMSReader) (err error) {
 			_, err = dec.ReadByte(&reflectedPdu, "Priority")
 		case X_MMS_RETRIEVE_STATUS:
 			_, err = dec.ReadByte(&reflectedPdu, "RetrieveStatus")
+		case X_MMS_READ_STATUS:
+			_, err = dec.ReadByte(&reflectedPdu, "ReadStatus")
+		case X_MMS_PREVIOUSLY_SENT_BY:
+			err = dec.ReadPreviouslySentBy(&reflectedPdu)
+		case X_MMS_PREVIOUSLY_SENT_DATE:
+			err = dec.ReadPreviouslySentDate(&reflectedPdu)
+		case X_MMS_STATUS:
+			_, err = dec.ReadByte(&reflectedPdu, "Status")
 		case X_MMS_RESPONSE_STATUS:
+			if isSliceField(&reflectedPdu, "ResponseStatus") {
+				err = dec.ReadStatusCountValue(&reflectedPdu, "ResponseStatus", func() (interface{}, error) {
+					return dec.ReadByte(nil, "")
+				})
+				break
+			}
 			_, err = dec.ReadByte(&reflectedPdu, "ResponseStatus")
 		case X_MMS_RESPONSE_TEXT:
+			if isSliceField(&reflectedPdu, "ResponseText") {
+				err = dec.ReadStatusCountValue(&reflectedPdu, "ResponseText", func() (interface{}, error) {
+					return dec.ReadString(nil, "")
+				})
+				break
+			}
 			_, err = dec.ReadString(&reflectedPdu, "ResponseText")
 		case X_MMS_DELIVERY_REPORT:
 			_, err = dec.ReadByte(&reflectedPdu, "DeliveryReport")
 		case X_MMS_READ_REPORT:
 			_, err = dec.ReadByte(&reflectedPdu, "ReadReport")
+		case X_MMS_REPORT_ALLOWED:
+			_, err = dec.ReadByte(&reflectedPdu, "ReportAllowed")
 		case X_MMS_MESSAGE_SIZE:
 			_, err = dec.ReadLongInteger(&reflectedPdu, "Size")
 		case DATE:
 			_, err = dec.ReadLongInteger(&reflectedPdu, "Date")
+		case X_MMS_STORE:
+			_, err = dec.ReadByte(&reflectedPdu, "Store")
+		case X_MMS_MM_STATE:
+			if isSliceField(&reflectedPdu, "MMState") {
+				var state byte
+				if state, err = dec.ReadByte(nil, ""); err == nil {
+					dec.appendPduField(&reflectedPdu, "MMState", state)
+				}
+				break
+			}
+			_, err = dec.ReadByte(&reflectedPdu, "MMState")
+		case X_MMS_MM_FLAGS:
+			err = dec.ReadMMFlags(&reflectedPdu)
+		case X_MMS_STORE_STATUS:
+			_, err = dec.ReadByte(&reflectedPdu, "StoreStatus")
+		case X_MMS_STORE_STATUS_TEXT:
+			_, err = dec.ReadEncodedString(&reflectedPdu, "StoreStatusText")
+		case X_MMS_STORED:
+			_, err = dec.ReadByte(&reflectedPdu, "Stored")
+		case X_MMS_ATTRIBUTES:
+			var attribute byte
+			if attribute, err = dec.ReadShortInteger(nil, ""); err == nil {
+				dec.appendPduField(&reflectedPdu, "Attributes", attribute)
+			}
+		case X_MMS_TOTALS:
+			_, err = dec.ReadByte(&reflectedPdu, "Totals")
+		case X_MMS_QUOTAS:
+			_, err = dec.ReadByte(&reflectedPdu, "Quotas")
+		case X_MMS_MBOX_TOTALS:
+			err = dec.ReadMboxCount(&reflectedPdu, "MboxTotals")
+		case X_MMS_MBOX_QUOTAS:
+			err = dec.ReadMboxCount(&reflectedPdu, "MboxQuotas")
+		case X_MMS_MESSAGE_COUNT:
+			_, err = dec.ReadInteger(&reflectedPdu, "MessageCount")
+		case X_MMS_START:
+			_, err = dec.ReadInteger(&reflectedPdu, "Start")
+		case X_MMS_LIMIT:
+			_, err = dec.ReadInteger(&reflectedPdu, "Limit")
+		case X_MMS_APPLIC_ID:
+			_, err = dec.ReadString(&reflectedPdu, "ApplicId")
+		case X_MMS_REPLY_APPLIC_ID:
+			_, err = dec.ReadString(&reflectedPdu, "ReplyApplicId")
+		case X_MMS_AUX_APPLIC_INFO:
+			_, err = dec.ReadString(&reflectedPdu, "AuxApplicInfo")
+		case X_MMS_CONTENT_CLASS:
+			_, err = dec.ReadByte(&reflectedPdu, "ContentClass")
+		case X_MMS_DRM_CONTENT:
+			_, err = dec.ReadByte(&reflectedPdu, "DrmContent")
+		case X_MMS_ADAPTATION_ALLOWED:
+			_, err = dec.ReadByte(&reflectedPdu, "AdaptationAllowed")
+		case X_MMS_CANCEL_ID:
+			_, err = dec.ReadString(&reflectedPdu, "CancelId")
+		case X_MMS_CANCEL_STATUS:
+			_, err = dec.ReadByte(&reflectedPdu, "CancelStatus")
 		default:
-			log.Printf("Skipping unrecognized header 0x%02x", param)
-			err = dec.skipFieldValue()
+			log.Printf("Keeping unrecognized header 0x%02x", param)
+			valueStart := dec.Offset + 1
+			if err = dec.skipFieldValue(); err == nil {
+				value := append([]byte(nil), dec.Data[valueStart:dec.Offset+1]...)
+				dec.appendPduField(&reflectedPdu, "UnknownHeaders", RawHeader{Code: param, Value: value})
+			}
 		}
 		if err != nil {
 			return err
@@ -488,6 +1092,111 @@ func (dec *MMSDecoder) Decode(pdu MMSReader) (err error) {
 	return nil
 }
 
+//DecodeAny decodes data into the PDU matching its X-Mms-Message-Type and
+//returns it, see MMSDecoder.DecodeAny.
+func DecodeAny(data []byte, limits ...DecoderLimits) (MMSReader, error) {
+	return NewDecoder(data, limits...).DecodeAny()
+}
+
+//readOutgoingContent reads the content type and body of the PDUs that keep
+//their content type as a string, the parts of a m-send.req or the message
+//carried by a m-mbox-upload.req.
+func (dec *MMSDecoder) readOutgoingContent(reflectedPdu *reflect.Value) error {
+	if !reflectedPdu.FieldByName("ContentType").IsValid() {
+		return fmt.Errorf("unexpected content type @%d in %s", dec.Offset, reflectedPdu.Type())
+	}
+	var ct Attachment
+	ctReflected := reflect.ValueOf(&ct).Elem()
+	if err := dec.ReadAttachment(&ctReflected); err != nil {
+		return err
+	}
+	dec.setPduField(reflectedPdu, "ContentType", ct.MediaType, setterString)
+	if ct.MediaType == VND_WAP_MMS_MESSAGE {
+		dec.Offset++
+		_, err := dec.ReadBoundedBytes(reflectedPdu, "Message", len(dec.Data))
+		return err
+	}
+	dec.setPduField(reflectedPdu, "ContentTypeStart", ct.Start, setterString)
+	dec.setPduField(reflectedPdu, "ContentTypeType", ct.Type, setterString)
+	return dec.ReadAttachmentParts(reflectedPdu)
+}
+
+//DecodeAny decodes the data into the PDU matching its X-Mms-Message-Type, which
+//is always the first header in an MMS PDU, and returns it. A MRetrieveConf
+//gets a newly generated UUID.
+func (dec *MMSDecoder) DecodeAny() (MMSReader, error) {
+	if err := dec.checkAvailable(1); err != nil {
+		return nil, &DecodeError{Header: X_MMS_MESSAGE_TYPE, Offset: dec.Offset, Err: err}
+	}
+	if dec.Data[dec.Offset] != X_MMS_MESSAGE_TYPE|0x80 {
+		return nil, &DecodeError{Header: X_MMS_MESSAGE_TYPE, Offset: dec.Offset,
+			Err: fmt.Errorf("%w, the first header is %#x", ErrUnexpectedMessageType, dec.Data[dec.Offset])}
+	}
+	messageType := dec.Data[dec.Offset+1]
+	var pdu MMSReader
+	switch messageType {
+	case TYPE_SEND_REQ:
+		pdu = &MSendReq{Type: TYPE_SEND_REQ}
+	case TYPE_SEND_CONF:
+		pdu = NewMSendConf()
+	case TYPE_NOTIFICATION_IND:
+		pdu = NewMNotificationInd()
+	case TYPE_NOTIFYRESP_IND:
+		pdu = NewMNotifyRespInd()
+	case TYPE_RETRIEVE_CONF:
+		pdu = NewMRetrieveConf(genUUID())
+	case TYPE_ACKNOWLEDGE_IND:
+		pdu = &MAcknowledgeInd{Type: TYPE_ACKNOWLEDGE_IND}
+	case TYPE_DELIVERY_IND:
+		pdu = NewMDeliveryInd()
+	case TYPE_READ_REC_IND:
+		pdu = &MReadRecInd{Type: TYPE_READ_REC_IND}
+	case TYPE_READ_ORIG_IND:
+		pdu = NewMReadOrigInd()
+	case TYPE_FORWARD_REQ:
+		pdu = &MForwardReq{Type: TYPE_FORWARD_REQ}
+	case TYPE_FORWARD_CONF:
+		pdu = NewMForwardConf()
+	case TYPE_MBOX_STORE_REQ:
+		pdu = &MMboxStoreReq{Type: TYPE_MBOX_STORE_REQ}
+	case TYPE_MBOX_STORE_CONF:
+		pdu = NewMMboxStoreConf()
+	case TYPE_MBOX_VIEW_REQ:
+		pdu = &MMboxViewReq{Type: TYPE_MBOX_VIEW_REQ}
+	case TYPE_MBOX_VIEW_CONF:
+		pdu = NewMMboxViewConf()
+	case TYPE_MBOX_UPLOAD_REQ:
+		pdu = &MMboxUploadReq{Type: TYPE_MBOX_UPLOAD_REQ}
+	case TYPE_MBOX_UPLOAD_CONF:
+		pdu = NewMMboxUploadConf()
+	case TYPE_MBOX_DELETE_REQ:
+		pdu = &MMboxDeleteReq{Type: TYPE_MBOX_DELETE_REQ}
+	case TYPE_MBOX_DELETE_CONF:
+		pdu = NewMMboxDeleteConf()
+	case TYPE_MBOX_DESCR:
+		pdu = &MMboxDescr{Type: TYPE_MBOX_DESCR}
+	case TYPE_CANCEL_REQ:
+		pdu = NewMCancelReq()
+	case TYPE_CANCEL_CONF:
+		pdu = &MCancelConf{Type: TYPE_CANCEL_CONF}
+	default:
+		return nil, &DecodeError{Type: messageType, Header: X_MMS_MESSAGE_TYPE, Offset: dec.Offset + 1,
+			Err: fmt.Errorf("%w %#x", ErrUnexpectedMessageType, messageType)}
+	}
+	if err := dec.Decode(pdu); err != nil {
+		return nil, err
+	}
+	return pdu, nil
+}
+
+func (dec *MMSDecoder) decodeError(reflectedPdu *reflect.Value, param byte, err error) *DecodeError {
+	decodeErr := &DecodeError{Header: param, Offset: dec.Offset, Err: err}
+	if pduType := reflectedPdu.FieldByName("Type"); pduType.IsValid() && canHold(pduType, uint64(0)) {
+		decodeErr.Type = byte(pduType.Uint())
+	}
+	return decodeErr
+}
+
 func (dec *MMSDecoder) GetLog() string {
 	return dec.log
 }
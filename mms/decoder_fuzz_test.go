@@ -0,0 +1,62 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mms
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+//FuzzDecode feeds arbitrary data to the decoder for every PDU type that is
+//decoded from the network, the decoder must return an error instead of
+//panicking on malformed input.
+func FuzzDecode(f *testing.F) {
+	payloads, err := filepath.Glob("test_payloads/*")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, payload := range payloads {
+		data, err := ioutil.ReadFile(payload)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pdus := []MMSReader{
+			NewMRetrieveConf("1"),
+			NewMNotificationInd(),
+			NewMSendConf(),
+			NewMDeliveryInd(),
+			NewMReadOrigInd(),
+		}
+		for _, pdu := range pdus {
+			NewDecoder(data).Decode(pdu)
+		}
+		DecodeAny(data, DefaultDecoderLimits)
+	})
+}
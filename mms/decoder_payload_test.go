@@ -67,3 +67,13 @@ func (s *PayloadDecoderTestSuite) TestDecodeInvalidMSendConf(c *C) {
 	c.Check(mSendConf.TransactionId, Equals, "")
 	mSendConf.Status()
 }
+
+func (s *PayloadDecoderTestSuite) TestDecodeMRetrieveConfWithDefaultLimits(c *C) {
+	inputBytes, err := ioutil.ReadFile("test_payloads/m-retrieve.conf_success")
+	c.Assert(err, IsNil)
+
+	mRetrieveConf := NewMRetrieveConf("55555555")
+	dec := NewDecoder(inputBytes, DefaultDecoderLimits)
+	c.Assert(dec.Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.From, Equals, "11111111111/TYPE=PLMN")
+}
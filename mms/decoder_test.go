@@ -23,6 +23,8 @@ package mms
 
 import (
 	"errors"
+	"reflect"
+	"time"
 
 	. "launchpad.net/gocheck"
 )
@@ -38,11 +40,11 @@ func (s *DecoderTestSuite) TestDecodeStringNoNullByteTerminator(c *C) {
 		//<html>
 		0x3c, 0x68, 0x74, 0x6d, 0x6c, 0x3e,
 	}
-	expectedErr := errors.New("reached end of data while trying to read string: <html>")
 	dec := NewDecoder(inputBytes)
 	str, err := dec.ReadString(nil, "")
 	c.Check(str, Equals, "")
-	c.Check(err, DeepEquals, expectedErr)
+	c.Check(err, ErrorMatches, "message ended prematurely, reached end of data while trying to read string: <html>")
+	c.Check(errors.Is(err, ErrTruncated), Equals, true)
 }
 
 func (s *DecoderTestSuite) TestDecodeStringWithNullByteTerminator(c *C) {
@@ -57,3 +59,580 @@ func (s *DecoderTestSuite) TestDecodeStringWithNullByteTerminator(c *C) {
 	c.Check(str, Equals, "<smil>")
 	c.Check(err, IsNil)
 }
+
+func (s *DecoderTestSuite) TestDecodeMDeliveryInd(c *C) {
+	inputBytes := []byte{
+		//Message Type m-delivery.ind
+		0x8C, 0x86,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Message Id "0123456"
+		0x8B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// To "+12345/TYPE=PLMN"
+		0x97, 0x2b, 0x31, 0x32, 0x33, 0x34, 0x35, 0x2f, 0x54, 0x59, 0x50, 0x45, 0x3d, 0x50, 0x4c, 0x4d, 0x4e, 0x00,
+		// Date
+		0x85, 0x04, 0x54, 0x5a, 0xc0, 0x37,
+		// Status retrieved
+		0x95, 0x81,
+	}
+	mDeliveryInd := NewMDeliveryInd()
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.Decode(mDeliveryInd), IsNil)
+	c.Check(mDeliveryInd.Version, Equals, byte(MMS_MESSAGE_VERSION_1_2))
+	c.Check(mDeliveryInd.MessageId, Equals, "0123456")
+	c.Check(mDeliveryInd.To, DeepEquals, []string{"+12345/TYPE=PLMN"})
+	c.Check(mDeliveryInd.Date, Equals, uint64(0x545ac037))
+	c.Check(mDeliveryInd.Status, Equals, byte(STATUS_RETRIEVED))
+}
+
+func (s *DecoderTestSuite) TestDecodeMDeliveryIndAsMNotificationInd(c *C) {
+	inputBytes := []byte{
+		//Message Type m-delivery.ind
+		0x8C, 0x86,
+		// MMS Version 1.2
+		0x8D, 0x92,
+	}
+	dec := NewDecoder(inputBytes)
+	err := dec.Decode(NewMNotificationInd())
+	c.Assert(err, FitsTypeOf, &DecodeError{})
+	decodeErr := err.(*DecodeError)
+	c.Check(decodeErr.Type, Equals, byte(TYPE_NOTIFICATION_IND))
+	c.Check(decodeErr.Header, Equals, byte(X_MMS_MESSAGE_TYPE))
+	c.Check(decodeErr.HeaderName(), Equals, "X-Mms-Message-Type")
+	c.Check(decodeErr.Offset, Equals, 1)
+	c.Check(errors.Is(err, ErrUnexpectedMessageType), Equals, true)
+	c.Check(err, ErrorMatches, "cannot decode m-notification.ind header X-Mms-Message-Type @1: unexpected message type, expected 82 got 86")
+}
+
+func (s *DecoderTestSuite) TestDecodeMReadOrigInd(c *C) {
+	inputBytes := []byte{
+		//Message Type m-read-orig.ind
+		0x8C, 0x88,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Message Id "0123456"
+		0x8B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// To "+1/TYPE=PLMN"
+		0x97, 0x2b, 0x31, 0x2f, 0x54, 0x59, 0x50, 0x45, 0x3d, 0x50, 0x4c, 0x4d, 0x4e, 0x00,
+		// From "+2/TYPE=PLMN"
+		0x89, 0x0e, 0x80, 0x2b, 0x32, 0x2f, 0x54, 0x59, 0x50, 0x45, 0x3d, 0x50, 0x4c, 0x4d, 0x4e, 0x00,
+		// Date
+		0x85, 0x04, 0x54, 0x5a, 0xc0, 0x37,
+		// Read Status deleted without being read
+		0x9B, 0x81,
+	}
+	mReadOrigInd := NewMReadOrigInd()
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.Decode(mReadOrigInd), IsNil)
+	c.Check(mReadOrigInd.MessageId, Equals, "0123456")
+	c.Check(mReadOrigInd.To, DeepEquals, []string{"+1/TYPE=PLMN"})
+	c.Check(mReadOrigInd.From, Equals, "+2/TYPE=PLMN")
+	c.Check(mReadOrigInd.Date, Equals, uint64(0x545ac037))
+	c.Check(mReadOrigInd.ReadStatus, Equals, ReadStatusDeleted)
+}
+
+func (s *DecoderTestSuite) TestDecodeMNotificationIndAbsoluteExpiry(c *C) {
+	inputBytes := []byte{
+		//Message Type m-notification.ind
+		0x8C, 0x82,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Expiry absolute
+		0x88, 0x06, 0x80, 0x04, 0x54, 0x5a, 0xc0, 0x37,
+		// Message Size
+		0x8E, 0x02, 0x10, 0x00,
+	}
+	mNotificationInd := NewMNotificationInd()
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.Decode(mNotificationInd), IsNil)
+	c.Check(mNotificationInd.Expiry, Equals, uint64(0x545ac037))
+	c.Check(mNotificationInd.Size, Equals, uint64(0x1000))
+}
+
+func (s *DecoderTestSuite) TestDecodeMNotificationIndMMS13Headers(c *C) {
+	inputBytes := []byte{
+		//Message Type m-notification.ind
+		0x8C, 0x82,
+		// MMS Version 1.3
+		0x8D, 0x93,
+		// Content Class text
+		0xBA, 0x80,
+		// Content Location "a"
+		0x83, 0x61, 0x00,
+		// DRM Content Yes
+		0xBB, 0x80,
+		// Adaptation Allowed No
+		0xBC, 0x81,
+		// Applic ID "app"
+		0xB7, 0x61, 0x70, 0x70, 0x00,
+		// Reply Applic ID "rep"
+		0xB8, 0x72, 0x65, 0x70, 0x00,
+		// Aux Applic Info "aux"
+		0xB9, 0x61, 0x75, 0x78, 0x00,
+	}
+	mNotificationInd := NewMNotificationInd()
+	c.Assert(NewDecoder(inputBytes).Decode(mNotificationInd), IsNil)
+	c.Check(mNotificationInd.ContentLocation, Equals, "a")
+	c.Check(mNotificationInd.ContentClass, Equals, ContentClassText)
+	c.Check(mNotificationInd.DrmContent, Equals, DrmContentYes)
+	c.Check(mNotificationInd.AdaptationAllowed, Equals, AdaptationAllowedNo)
+	c.Check(mNotificationInd.ApplicId, Equals, "app")
+	c.Check(mNotificationInd.ReplyApplicId, Equals, "rep")
+	c.Check(mNotificationInd.AuxApplicInfo, Equals, "aux")
+	c.Check(mNotificationInd.UnknownHeaders, HasLen, 0)
+}
+
+func (s *DecoderTestSuite) TestDecodeMNotificationIndRelativeExpiry(c *C) {
+	inputBytes := []byte{
+		//Message Type m-notification.ind
+		0x8C, 0x82,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Expiry relative 7200 seconds
+		0x88, 0x04, 0x81, 0x02, 0x1C, 0x20,
+		// Message Size
+		0x8E, 0x02, 0x10, 0x00,
+	}
+	mNotificationInd := NewMNotificationInd()
+	dec := NewDecoder(inputBytes)
+	now := uint64(time.Now().Unix())
+	c.Assert(dec.Decode(mNotificationInd), IsNil)
+	c.Check(mNotificationInd.Expiry >= now+7200, Equals, true)
+	c.Check(mNotificationInd.Size, Equals, uint64(0x1000))
+}
+
+func (s *DecoderTestSuite) TestDecodeUCS2SubjectWithWideTerminator(c *C) {
+	inputBytes := []byte{
+		//Message Type m-retrieve.conf
+		0x8C, 0x84,
+		// Subject "Āb" in UCS-2 with a two octet End-of-string
+		0x96, 0x09, 0x02, 0x03, 0xE8, 0x01, 0x00, 0x00, 0x62, 0x00, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+	}
+	mRetrieveConf := NewMRetrieveConf("1")
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.Subject, Equals, "Āb")
+	c.Check(mRetrieveConf.Version, Equals, byte(MMS_MESSAGE_VERSION_1_2))
+}
+
+func (s *DecoderTestSuite) TestDecodeTruncatedUintVar(c *C) {
+	inputBytes := []byte{
+		//stub byte
+		0x80,
+		// continuation bit set on the last octet
+		0x81, 0x82,
+	}
+	dec := NewDecoder(inputBytes)
+	_, err := dec.ReadUintVar(nil, "")
+	c.Check(err, DeepEquals, &TruncatedError{Offset: 2, Needed: 1, Length: 3})
+}
+
+func (s *DecoderTestSuite) TestDecodeTruncatedMNotificationInd(c *C) {
+	inputBytes := []byte{
+		//Message Type m-notification.ind
+		0x8C, 0x82,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Message Size as a long integer missing two octets
+		0x8E, 0x03, 0x01,
+	}
+	dec := NewDecoder(inputBytes)
+	err := dec.Decode(NewMNotificationInd())
+	c.Check(err, DeepEquals, &DecodeError{
+		Type:   TYPE_NOTIFICATION_IND,
+		Header: X_MMS_MESSAGE_SIZE,
+		Offset: 8,
+		Err:    &TruncatedError{Offset: 8, Needed: 3, Length: 10},
+	})
+	c.Check(errors.Is(err, ErrTruncated), Equals, true)
+}
+
+func (s *DecoderTestSuite) TestDecodeIntegerTypeParameter(c *C) {
+	inputBytes := []byte{
+		//stub byte
+		0x80,
+		// Content-Type length 3, image/jpeg with a Type parameter
+		0x03, 0x9e, 0x83, 0x81,
+	}
+	var attachment Attachment
+	attachmentReflected := reflect.ValueOf(&attachment).Elem()
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.ReadAttachment(&attachmentReflected), IsNil)
+	c.Check(attachment.MediaType, Equals, "image/jpeg")
+	c.Check(attachment.Type, Equals, "text/*")
+}
+
+func (s *DecoderTestSuite) TestDecodeUnknownCharset(c *C) {
+	inputBytes := []byte{
+		//Message Type m-notification.ind
+		0x8C, 0x82,
+		// Subject with an unassigned charset
+		0x96, 0x04, 0x02, 0x7f, 0x7f, 0x00,
+	}
+	dec := NewDecoder(inputBytes)
+	err := dec.Decode(NewMNotificationInd())
+	c.Check(errors.Is(err, ErrUnknownCharset), Equals, true)
+	c.Check(err.(*DecodeError).Header, Equals, byte(SUBJECT))
+}
+
+func (s *DecoderTestSuite) TestDecodeUintVarTooLong(c *C) {
+	inputBytes := []byte{
+		//stub byte
+		0x80,
+		0x81, 0x81, 0x81, 0x81, 0x81, 0x01,
+	}
+	dec := NewDecoder(inputBytes)
+	_, err := dec.ReadUintVar(nil, "")
+	c.Check(err, ErrorMatches, "uintvar @5 is longer than 5 octets")
+}
+
+func (s *DecoderTestSuite) TestDecodeLimits(c *C) {
+	inputBytes := []byte{
+		//Message Type m-retrieve.conf
+		0x8C, 0x84,
+		// Message Id "0123456"
+		0x8B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// Content Type application/vnd.wap.multipart.mixed
+		0x84, 0xa3,
+		// 3 parts
+		0x03,
+	}
+	limits := DecoderLimits{MaxParts: 2}
+	err := NewDecoder(inputBytes, limits).Decode(NewMRetrieveConf("1"))
+	c.Check(errors.Is(err, ErrLimitExceeded), Equals, true)
+	c.Check(err, ErrorMatches, ".*3 parts is more than 2")
+
+	limits = DecoderLimits{MaxStringLength: 6}
+	err = NewDecoder(inputBytes, limits).Decode(NewMRetrieveConf("1"))
+	c.Check(errors.Is(err, ErrLimitExceeded), Equals, true)
+	c.Check(err.(*DecodeError).Header, Equals, byte(MESSAGE_ID))
+
+	limits = DecoderLimits{MaxHeaders: 2}
+	err = NewDecoder(inputBytes, limits).Decode(NewMRetrieveConf("1"))
+	c.Check(errors.Is(err, ErrLimitExceeded), Equals, true)
+
+	limits = DecoderLimits{MaxSize: len(inputBytes) - 1}
+	err = NewDecoder(inputBytes, limits).Decode(NewMRetrieveConf("1"))
+	c.Check(errors.Is(err, ErrLimitExceeded), Equals, true)
+}
+
+func (s *DecoderTestSuite) TestDecodeAny(c *C) {
+	inputBytes := []byte{
+		//Message Type m-delivery.ind
+		0x8C, 0x86,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Message Id "0123456"
+		0x8B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// Status retrieved
+		0x95, 0x81,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MDeliveryInd{})
+	mDeliveryInd := pdu.(*MDeliveryInd)
+	c.Check(mDeliveryInd.MessageId, Equals, "0123456")
+	c.Check(mDeliveryInd.Status, Equals, byte(STATUS_RETRIEVED))
+}
+
+func (s *DecoderTestSuite) TestDecodeMForwardConf(c *C) {
+	inputBytes := []byte{
+		//Message Type m-forward.conf
+		0x8C, 0x8A,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Response Status Ok
+		0x92, 0x80,
+		// Message Id "0123456"
+		0x8B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MForwardConf{})
+	mForwardConf := pdu.(*MForwardConf)
+	c.Check(mForwardConf.TransactionId, Equals, "1")
+	c.Check(mForwardConf.MessageId, Equals, "0123456")
+	c.Check(mForwardConf.Status(), IsNil)
+
+	// Response Status Error-unsupported-message
+	inputBytes[8] = ResponseStatusErrorUnsupportedMessage
+	mForwardConf = NewMForwardConf()
+	c.Assert(NewDecoder(inputBytes).Decode(mForwardConf), IsNil)
+	c.Check(mForwardConf.Status(), Equals, ErrPermanent)
+}
+
+func (s *DecoderTestSuite) TestDecodeAnyUnknownMessageType(c *C) {
+	inputBytes := []byte{
+		//Message Type 0x9F, which is not assigned
+		0x8C, 0x9F,
+		// MMS Version 1.2
+		0x8D, 0x92,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Check(pdu, IsNil)
+	c.Check(errors.Is(err, ErrUnexpectedMessageType), Equals, true)
+
+	pdu, err = DecodeAny(inputBytes[2:])
+	c.Check(pdu, IsNil)
+	c.Check(errors.Is(err, ErrUnexpectedMessageType), Equals, true)
+
+	pdu, err = DecodeAny(inputBytes[:1])
+	c.Check(pdu, IsNil)
+	c.Check(errors.Is(err, ErrTruncated), Equals, true)
+}
+
+func (s *DecoderTestSuite) TestDecodeContentTypeParameters(c *C) {
+	inputBytes := []byte{
+		//Message Type m-retrieve.conf
+		0x8C, 0x84,
+		// Content Type with a length of 43
+		0x84, 0x1F, 0x2B,
+		// application/vnd.wap.multipart.related
+		0xB3,
+		// Start <smil>
+		0x99, 0x3c, 0x73, 0x6d, 0x69, 0x6c, 0x3e, 0x00,
+		// Type application/smil
+		0x89, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x73, 0x6d, 0x69, 0x6c, 0x00,
+		// x-foo=bar
+		0x78, 0x2d, 0x66, 0x6f, 0x6f, 0x00, 0x62, 0x61, 0x72, 0x00,
+		// Creation Date
+		0x93, 0x02, 0x01, 0x00,
+		// Secure
+		0x90, 0x00,
+		// no parts
+		0x00,
+	}
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(NewDecoder(inputBytes).Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.Content.MediaType, Equals, "application/vnd.wap.multipart.related")
+	c.Check(mRetrieveConf.Content.Start, Equals, "<smil>")
+	c.Check(mRetrieveConf.Content.Type, Equals, "application/smil")
+	c.Check(mRetrieveConf.Content.Parameters, DeepEquals, map[string]string{"x-foo": "bar"})
+	c.Check(mRetrieveConf.Content.CreationDate, Equals, uint64(0x100))
+	c.Check(mRetrieveConf.Content.Secure, Equals, true)
+	c.Check(mRetrieveConf.Attachments, HasLen, 0)
+}
+
+func (s *DecoderTestSuite) TestDecodeUntypedParameters(c *C) {
+	inputBytes := []byte{
+		//stub byte
+		0x80,
+		// Content-Type length 24, text/plain
+		0x18, 0x83,
+		// Untyped name="a.txt"
+		0x6e, 0x61, 0x6d, 0x65, 0x00, 0x61, 0x2e, 0x74, 0x78, 0x74, 0x00,
+		// Untyped size=10 as an integer
+		0x73, 0x69, 0x7a, 0x65, 0x00, 0x8a,
+		// Max Age 60
+		0x8e, 0xbc,
+		// Differences Subject
+		0x87, 0x96,
+		// Level 1
+		0x82, 0x81,
+	}
+	var attachment Attachment
+	attachmentReflected := reflect.ValueOf(&attachment).Elem()
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.ReadAttachment(&attachmentReflected), IsNil)
+	c.Check(dec.Offset, Equals, len(inputBytes)-1)
+	c.Check(attachment.MediaType, Equals, "text/plain")
+	c.Check(attachment.Name, Equals, "a.txt")
+	c.Check(attachment.Parameters, DeepEquals, map[string]string{"size": "10"})
+	c.Check(attachment.MaxAge, Equals, uint64(60))
+	c.Check(attachment.Differences, Equals, "Subject")
+	c.Check(attachment.Level, Equals, byte(1))
+}
+
+func (s *DecoderTestSuite) TestDecodeNestedMultipart(c *C) {
+	inputBytes := []byte{
+		//Message Type m-retrieve.conf
+		0x8C, 0x84,
+		// Content Type application/vnd.wap.multipart.mixed
+		0x84, 0xA3,
+		// 2 parts
+		0x02,
+		// text/plain "hi"
+		0x01, 0x02, 0x83, 0x68, 0x69,
+		// application/vnd.wap.multipart.alternative
+		0x01, 0x07, 0xA6,
+		// 1 part, image/jpeg
+		0x01, 0x01, 0x03, 0x9E, 0x01, 0x02, 0x03,
+	}
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(NewDecoder(inputBytes).Decode(mRetrieveConf), IsNil)
+	c.Assert(mRetrieveConf.Attachments, HasLen, 2)
+	alternative := mRetrieveConf.Attachments[1]
+	c.Check(alternative.MediaType, Equals, "application/vnd.wap.multipart.alternative")
+	c.Assert(alternative.Parts, HasLen, 1)
+	c.Check(alternative.Parts[0].MediaType, Equals, "image/jpeg")
+
+	dataParts := mRetrieveConf.GetDataParts()
+	c.Assert(dataParts, HasLen, 2)
+	c.Check(dataParts[0].MediaType, Equals, "text/plain")
+	c.Check(dataParts[0].Offset, Equals, 8)
+	c.Check(dataParts[1].MediaType, Equals, "image/jpeg")
+	c.Check(dataParts[1].Offset, Equals, 17)
+	c.Check(dataParts[1].Data, DeepEquals, inputBytes[17:20])
+}
+
+func (s *DecoderTestSuite) TestDecodeNestedMultipartLimit(c *C) {
+	inputBytes := []byte{
+		//Message Type m-retrieve.conf
+		0x8C, 0x84,
+		// Content Type application/vnd.wap.multipart.mixed
+		0x84, 0xA3,
+		// 1 part, application/vnd.wap.multipart.mixed
+		0x01, 0x01, 0x02, 0xA3,
+		// 0 parts
+		0x00, 0x00,
+	}
+	err := NewDecoder(inputBytes, DecoderLimits{MaxNesting: 1}).Decode(NewMRetrieveConf("1"))
+	c.Check(errors.Is(err, ErrLimitExceeded), Equals, true)
+
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(NewDecoder(inputBytes, DecoderLimits{MaxNesting: 2}).Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.Attachments[0].Parts, HasLen, 0)
+	c.Check(mRetrieveConf.GetDataParts(), HasLen, 0)
+}
+
+func (s *DecoderTestSuite) TestDecodeMMboxStoreConf(c *C) {
+	inputBytes := []byte{
+		//Message Type m-mbox-store.conf
+		0x8C, 0x8C,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Content Location "a"
+		0x83, 0x61, 0x00,
+		// Store Status Success
+		0xA5, 0x80,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxStoreConf{})
+	mMboxStoreConf := pdu.(*MMboxStoreConf)
+	c.Check(mMboxStoreConf.ContentLocation, Equals, "a")
+	c.Check(mMboxStoreConf.Status(), IsNil)
+
+	// Store Status Error-transient-network-problem
+	inputBytes[11] = StoreStatusErrorTransientNetworkProblem
+	c.Assert(NewDecoder(inputBytes).Decode(mMboxStoreConf), IsNil)
+	c.Check(mMboxStoreConf.Status(), Equals, ErrTransient)
+
+	// Store Status Error-permanent-mmbox-full
+	inputBytes[11] = StoreStatusErrorPermanentMMBoxFull
+	c.Assert(NewDecoder(inputBytes).Decode(mMboxStoreConf), IsNil)
+	c.Check(mMboxStoreConf.Status(), Equals, ErrPermanent)
+}
+
+func (s *DecoderTestSuite) TestDecodeMMboxViewConf(c *C) {
+	inputBytes := []byte{
+		//Message Type m-mbox-view.conf
+		0x8C, 0x8E,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Response Status Ok
+		0x92, 0x80,
+		// Mbox Totals 1 message
+		0xAA, 0x02, 0x80, 0x81,
+		// Mbox Quotas 4096 octets
+		0xAC, 0x04, 0x81, 0x02, 0x10, 0x00,
+		// Message Count 1
+		0xAD, 0x81,
+		// Content Type application/vnd.wap.multipart.mixed
+		0x84, 0xA3,
+		// 1 part, application/vnd.wap.mms-message
+		0x01, 0x01, 0x16, 0xBE,
+		//Message Type m-mbox-descr
+		0x8C, 0x93,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Content Location "a"
+		0x83, 0x61, 0x00,
+		// MM State New
+		0xA3, 0x82,
+		// MM Flags Add "ab"
+		0xA4, 0x04, 0x80, 0x61, 0x62, 0x00,
+		// Subject "hi"
+		0x96, 0x68, 0x69, 0x00,
+		// Message Size 32
+		0x8E, 0x01, 0x20,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxViewConf{})
+	mMboxViewConf := pdu.(*MMboxViewConf)
+	c.Check(mMboxViewConf.Status(), IsNil)
+	c.Check(mMboxViewConf.MboxTotals, DeepEquals, []MboxCount{{Token: MboxCountMessages, Value: 1}})
+	c.Check(mMboxViewConf.MboxQuotas, DeepEquals, []MboxCount{{Token: MboxCountSize, Value: 4096}})
+	c.Check(mMboxViewConf.MessageCount, Equals, uint64(1))
+
+	descrs, err := mMboxViewConf.Descriptions()
+	c.Assert(err, IsNil)
+	c.Assert(descrs, HasLen, 1)
+	c.Check(descrs[0].ContentLocation, Equals, "a")
+	c.Check(descrs[0].MMState, Equals, MMStateNew)
+	c.Check(descrs[0].MMFlags, DeepEquals, []MMFlag{{Token: MMFlagsAdd, Keyword: "ab"}})
+	c.Check(descrs[0].Subject, Equals, "hi")
+	c.Check(descrs[0].Size, Equals, uint64(32))
+}
+
+func (s *DecoderTestSuite) TestDecodeMMboxDeleteConf(c *C) {
+	inputBytes := []byte{
+		//Message Type m-mbox-delete.conf
+		0x8C, 0x92,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Content Location 1 "a"
+		0x83, 0x03, 0x81, 0x61, 0x00,
+		// Response Status 1 Ok
+		0x92, 0x02, 0x81, 0x80,
+		// Content Location 2 "b"
+		0x83, 0x03, 0x82, 0x62, 0x00,
+		// Response Status 2 Error-permanent-message-not-found
+		0x92, 0x02, 0x82, 0xE4,
+		// Response Text 2 "gone"
+		0x93, 0x06, 0x82, 0x67, 0x6F, 0x6E, 0x65, 0x00,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxDeleteConf{})
+	mMboxDeleteConf := pdu.(*MMboxDeleteConf)
+	c.Check(mMboxDeleteConf.ContentLocation, DeepEquals, []string{"a", "b"})
+	c.Check(mMboxDeleteConf.ResponseStatus, DeepEquals, []byte{ResponseStatusOk, ResponseStatusErrorPermanentMessageNotFound})
+	c.Check(mMboxDeleteConf.ResponseText, DeepEquals, []string{"gone"})
+}
+
+func (s *DecoderTestSuite) TestDecodeMCancelReq(c *C) {
+	inputBytes := []byte{
+		//Message Type m-cancel.req
+		0x8C, 0x96,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.3
+		0x8D, 0x93,
+		// Cancel Id "http://a/1"
+		0xBE, 0x68, 0x74, 0x74, 0x70, 0x3A, 0x2F, 0x2F, 0x61, 0x2F, 0x31, 0x00,
+	}
+	pdu, err := DecodeAny(inputBytes)
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MCancelReq{})
+	mCancelReq := pdu.(*MCancelReq)
+	c.Check(mCancelReq.TransactionId, Equals, "1")
+	c.Check(mCancelReq.CancelId, Equals, "http://a/1")
+
+	c.Check(mCancelReq.Cancels(&MNotificationInd{ContentLocation: "http://a/1"}), Equals, true)
+	c.Check(mCancelReq.Cancels(&MNotificationInd{ContentLocation: "http://a/2"}), Equals, false)
+	c.Check(NewMCancelReq().Cancels(&MNotificationInd{}), Equals, false)
+}
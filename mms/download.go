@@ -30,7 +30,13 @@ import (
 	"launchpad.net/udm"
 )
 
-func (pdu *MNotificationInd) DownloadContent(proxyHost string, proxyPort int32) (string, error) {
+//ErrDownloadCancelled is returned by DownloadContent when the download is
+//cancelled before it finishes.
+var ErrDownloadCancelled = errors.New("download cancelled")
+
+//DownloadContent downloads the message notified by pdu through the proxy and
+//returns the path to the downloaded file, closing cancel aborts it.
+func (pdu *MNotificationInd) DownloadContent(proxyHost string, proxyPort int32, cancel <-chan struct{}) (string, error) {
 	downloadManager, err := udm.NewDownloadManager()
 	if err != nil {
 		return "", err
@@ -55,6 +61,11 @@ func (pdu *MNotificationInd) DownloadContent(proxyHost string, proxyPort int32)
 			return "", fmt.Errorf("Download timeout exceeded while fetching %s", pdu.ContentLocation)
 		case err := <-e:
 			return "", err
+		case <-cancel:
+			if err := download.Cancel(); err != nil {
+				log.Print("Cannot cancel download of ", pdu.ContentLocation, ": ", err)
+			}
+			return "", ErrDownloadCancelled
 		}
 	}
 }
@@ -23,6 +23,7 @@ package mms
 
 import (
 	"bytes"
+	"reflect"
 
 	. "launchpad.net/gocheck"
 )
@@ -108,3 +109,240 @@ func (s *EncodeDecodeTestSuite) TestLength(c *C) {
 		c.Check(integer, Equals, testLengths[i], Commentf("%d != %d with encoded bytes starting at %d: %d", integer, testLengths[i], s.dec.Offset, bytes))
 	}
 }
+
+func (s *EncodeDecodeTestSuite) TestPreviouslySent(c *C) {
+	c.Assert(s.enc.writeByteParam(X_MMS_MESSAGE_TYPE, TYPE_RETRIEVE_CONF), IsNil)
+	c.Assert(s.enc.writePreviouslySentBy(SentBy{Count: 2, Address: "+12345/TYPE=PLMN"}), IsNil)
+	c.Assert(s.enc.writePreviouslySentDate(SentDate{Count: 2, Date: 1415233591}), IsNil)
+	c.Assert(s.enc.writePreviouslySentBy(SentBy{Count: 3, Address: "+54321/TYPE=PLMN"}), IsNil)
+	c.Assert(s.enc.writePreviouslySentDate(SentDate{Count: 3, Date: 1415233600}), IsNil)
+	s.dec = NewDecoder(s.bytes.Bytes())
+	s.dec.Offset = 1
+
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(s.dec.Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.PreviouslySentBy, DeepEquals, []SentBy{{2, "+12345/TYPE=PLMN"}, {3, "+54321/TYPE=PLMN"}})
+	c.Check(mRetrieveConf.PreviouslySentDate, DeepEquals, []SentDate{{2, 1415233591}, {3, 1415233600}})
+}
+
+func (s *EncodeDecodeTestSuite) TestEncodedStringCharsets(c *C) {
+	subjects := []struct{ charset, text string }{
+		{"", "Hello"},
+		{"", "Ñandú"},
+		{"utf-8", "こんにちは"},
+		{"iso-8859-1", "Ñandú"},
+		{"iso-8859-2", "Zażółć gęślą jaźń"},
+		{"iso-8859-5", "Привет"},
+		{"iso-10646-ucs-2", "Ā你好"},
+		{"shift_JIS", "こんにちは"},
+		{"big5", "你好"},
+	}
+	for i := range subjects {
+		s.SetUpTest(c)
+		c.Assert(s.enc.writeByteParam(X_MMS_MESSAGE_TYPE, TYPE_RETRIEVE_CONF), IsNil)
+		c.Assert(s.enc.writeEncodedStringParam(SUBJECT, subjects[i].text, subjects[i].charset), IsNil)
+		s.dec = NewDecoder(s.bytes.Bytes())
+		s.dec.Offset = 1
+
+		mRetrieveConf := NewMRetrieveConf("1")
+		c.Assert(s.dec.Decode(mRetrieveConf), IsNil, Commentf("%s: %#x", subjects[i].charset, s.bytes.Bytes()))
+		c.Check(mRetrieveConf.Subject, Equals, subjects[i].text, Commentf("%s: %#x", subjects[i].charset, s.bytes.Bytes()))
+	}
+}
+
+func (s *EncodeDecodeTestSuite) TestAddressLists(c *C) {
+	to := []string{"+11111/TYPE=PLMN"}
+	cc := []string{"+22222/TYPE=PLMN", "+33333/TYPE=PLMN"}
+	bcc := []string{"+44444/TYPE=PLMN"}
+	c.Assert(s.enc.writeByteParam(X_MMS_MESSAGE_TYPE, TYPE_RETRIEVE_CONF), IsNil)
+	c.Assert(s.enc.writeAddressList(TO, reflect.ValueOf(to)), IsNil)
+	c.Assert(s.enc.writeAddressList(CC, reflect.ValueOf(cc)), IsNil)
+	c.Assert(s.enc.writeAddressList(BCC, reflect.ValueOf(bcc)), IsNil)
+	s.dec = NewDecoder(s.bytes.Bytes())
+	s.dec.Offset = 1
+
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(s.dec.Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.To, DeepEquals, to)
+	c.Check(mRetrieveConf.Cc, DeepEquals, cc)
+	c.Check(mRetrieveConf.Bcc, DeepEquals, bcc)
+}
+
+func (s *EncodeDecodeTestSuite) TestMixedAddressList(c *C) {
+	to, err := NewAddresses([]string{"+11111", "user@example.com", "12345"}, "")
+	c.Assert(err, IsNil)
+	mSendReq := NewMSendReq(to, []*Attachment{}, false, false)
+	c.Check(mSendReq.To, DeepEquals, []string{"+11111/TYPE=PLMN", "user@example.com", "12345/TYPE=PLMN"})
+
+	addresses := append(mSendReq.To, "José <jose@example.com>")
+	c.Assert(s.enc.writeByteParam(X_MMS_MESSAGE_TYPE, TYPE_RETRIEVE_CONF), IsNil)
+	c.Assert(s.enc.writeAddressList(TO, reflect.ValueOf(addresses)), IsNil)
+	s.dec = NewDecoder(s.bytes.Bytes())
+	s.dec.Offset = 1
+
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(s.dec.Decode(mRetrieveConf), IsNil)
+	c.Check(mRetrieveConf.To, DeepEquals, addresses)
+}
+
+func (s *EncodeDecodeTestSuite) TestMForwardReqRoundTrip(c *C) {
+	to := []Address{{Type: AddressPLMN, Value: "+11111"}, {Type: AddressEmail, Value: "user@example.com"}}
+	mForwardReq := NewMForwardReq(to, "http://mmsc.example.com/1", true, false)
+	mForwardReq.PreviouslySentBy = []SentBy{{Count: 3, Address: "+22222/TYPE=PLMN"}}
+	mForwardReq.PreviouslySentDate = []SentDate{{Count: 3, Date: 0x545ac037}}
+
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mForwardReq), IsNil)
+	pdu, err := DecodeAny(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MForwardReq{})
+	decoded := pdu.(*MForwardReq)
+	c.Check(decoded.TransactionId, Equals, mForwardReq.TransactionId)
+	c.Check(decoded.Version, Equals, byte(MMS_MESSAGE_VERSION_1_2))
+	c.Check(decoded.To, DeepEquals, []string{"+11111/TYPE=PLMN", "user@example.com"})
+	c.Check(decoded.DeliveryReport, Equals, DeliveryReportYes)
+	c.Check(decoded.ReadReport, Equals, ReadReportNo)
+	c.Check(decoded.PreviouslySentBy, DeepEquals, mForwardReq.PreviouslySentBy)
+	c.Check(decoded.PreviouslySentDate, DeepEquals, mForwardReq.PreviouslySentDate)
+	c.Check(decoded.ContentLocation, Equals, "http://mmsc.example.com/1")
+}
+
+func (s *EncodeDecodeTestSuite) TestUnknownHeadersRoundTrip(c *C) {
+	inputBytes := []byte{
+		//Message Type m-notifyresp.ind
+		0x8C, 0x83,
+		// Transaction Id "1"
+		0x98, 0x31, 0x00,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Status retrieved
+		0x95, 0x81,
+		// Report allowed
+		0x91, 0x80,
+		// Reply Charging Size, not decoded into any field
+		0x9F, 0x02, 0x01, 0x00,
+		// X-Carrier: abc
+		0x58, 0x2d, 0x43, 0x61, 0x72, 0x72, 0x69, 0x65, 0x72, 0x00, 0x61, 0x62, 0x63, 0x00,
+	}
+	mNotifyRespInd := NewMNotifyRespInd()
+	c.Assert(NewDecoder(inputBytes).Decode(mNotifyRespInd), IsNil)
+	c.Check(mNotifyRespInd.UnknownHeaders, DeepEquals, []RawHeader{
+		{Code: X_MMS_REPLY_CHARGING_SIZE, Value: []byte{0x02, 0x01, 0x00}},
+		{Name: "X-Carrier", Value: []byte("abc")},
+	})
+
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mNotifyRespInd), IsNil)
+	c.Check(b.Bytes(), DeepEquals, inputBytes)
+}
+
+func (s *EncodeDecodeTestSuite) TestMMboxViewReqRoundTrip(c *C) {
+	mMboxViewReq := NewMMboxViewReq()
+	mMboxViewReq.ContentLocation = []string{"http://mmsc.example.com/1", "http://mmsc.example.com/2"}
+	mMboxViewReq.MMState = []byte{MMStateNew, MMStateRetrieved}
+	mMboxViewReq.MMFlags = []MMFlag{{Token: MMFlagsFilter, Keyword: "work"}}
+	mMboxViewReq.Start = 10
+	mMboxViewReq.Limit = 200
+	mMboxViewReq.Attributes = []byte{SUBJECT, DATE}
+	mMboxViewReq.Totals = MboxYes
+
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mMboxViewReq), IsNil)
+	pdu, err := DecodeAny(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxViewReq{})
+	decoded := pdu.(*MMboxViewReq)
+	c.Check(decoded.TransactionId, Equals, mMboxViewReq.TransactionId)
+	c.Check(decoded.ContentLocation, DeepEquals, mMboxViewReq.ContentLocation)
+	c.Check(decoded.MMState, DeepEquals, mMboxViewReq.MMState)
+	c.Check(decoded.MMFlags, DeepEquals, mMboxViewReq.MMFlags)
+	c.Check(decoded.Start, Equals, uint64(10))
+	c.Check(decoded.Limit, Equals, uint64(200))
+	c.Check(decoded.Attributes, DeepEquals, mMboxViewReq.Attributes)
+	c.Check(decoded.Totals, Equals, MboxYes)
+	c.Check(decoded.Quotas, Equals, byte(0))
+}
+
+func (s *EncodeDecodeTestSuite) TestMMboxStoreAndDeleteReqRoundTrip(c *C) {
+	flags := []MMFlag{{Token: MMFlagsAdd, Keyword: "work"}, {Token: MMFlagsRemove, Keyword: "home"}}
+	mMboxStoreReq := NewMMboxStoreReq("http://mmsc.example.com/1", MMStateRetrieved, flags)
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mMboxStoreReq), IsNil)
+	pdu, err := DecodeAny(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxStoreReq{})
+	storeReq := pdu.(*MMboxStoreReq)
+	c.Check(storeReq.ContentLocation, Equals, "http://mmsc.example.com/1")
+	c.Check(storeReq.MMState, Equals, MMStateRetrieved)
+	c.Check(storeReq.MMFlags, DeepEquals, flags)
+
+	locations := []string{"http://mmsc.example.com/1", "http://mmsc.example.com/2"}
+	b.Reset()
+	c.Assert(NewEncoder(&b).Encode(NewMMboxDeleteReq(locations)), IsNil)
+	pdu, err = DecodeAny(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxDeleteReq{})
+	c.Check(pdu.(*MMboxDeleteReq).ContentLocation, DeepEquals, locations)
+}
+
+func (s *EncodeDecodeTestSuite) TestMMboxUploadReq(c *C) {
+	text := &Attachment{MediaType: "text/plain", ContentId: "<text0>", Data: []byte("hi")}
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, []*Attachment{text}, false, false)
+	mSendReq.Subject = "Draft"
+	mMboxUploadReq, err := mSendReq.NewMMboxUploadReq(MMStateDraft, nil)
+	c.Assert(err, IsNil)
+	c.Check(mMboxUploadReq.ContentType, Equals, VND_WAP_MMS_MESSAGE)
+	c.Check(mSendReq.Type, Equals, byte(TYPE_SEND_REQ))
+
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mMboxUploadReq), IsNil)
+	// the message follows the application/vnd.wap.mms-message content type
+	c.Check(bytes.HasSuffix(b.Bytes(), append([]byte{0x84, 0xBE}, mMboxUploadReq.Message...)), Equals, true)
+
+	descr := &MMboxDescr{Type: TYPE_MBOX_DESCR}
+	c.Assert(NewDecoder(mMboxUploadReq.Message).Decode(descr), IsNil)
+	c.Check(descr.To, DeepEquals, []string{"+11111/TYPE=PLMN"})
+	c.Check(descr.Subject, Equals, "Draft")
+	c.Assert(descr.Attachments, HasLen, 2)
+	c.Check(descr.Attachments[0].MediaType, Equals, "application/smil")
+	c.Check(string(descr.Attachments[1].Data), Equals, "hi")
+
+	pdu, err := DecodeAny(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MMboxUploadReq{})
+	decoded := pdu.(*MMboxUploadReq)
+	c.Check(decoded.TransactionId, Equals, mMboxUploadReq.TransactionId)
+	c.Check(decoded.MMState, Equals, MMStateDraft)
+	c.Check(decoded.ContentType, Equals, VND_WAP_MMS_MESSAGE)
+	c.Check(decoded.Message, DeepEquals, mMboxUploadReq.Message)
+}
+
+func (s *EncodeDecodeTestSuite) TestMSendReqRoundTrip(c *C) {
+	attachments := []*Attachment{
+		{MediaType: "image/jpeg", ContentId: "<image0>", Data: []byte{0xFF, 0xD8}},
+		{MediaType: "text/plain", ContentId: "<text0>", Data: []byte("hi")},
+	}
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, attachments, true, false)
+	mSendReq.Subject = "Hello"
+
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mSendReq), IsNil)
+	pdu, err := DecodeAny(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(pdu, FitsTypeOf, &MSendReq{})
+	decoded := pdu.(*MSendReq)
+	c.Check(decoded.Type, Equals, byte(TYPE_SEND_REQ))
+	c.Check(decoded.TransactionId, Equals, mSendReq.TransactionId)
+	c.Check(decoded.To, DeepEquals, mSendReq.To)
+	c.Check(decoded.Subject, Equals, "Hello")
+	c.Check(decoded.DeliveryReport, Equals, DeliveryReportYes)
+	c.Check(decoded.ContentType, Equals, "application/vnd.wap.multipart.related")
+	c.Check(decoded.ContentTypeStart, Equals, mSendReq.ContentTypeStart)
+	c.Check(decoded.ContentTypeType, Equals, "application/smil")
+	c.Assert(decoded.Attachments, HasLen, 3)
+	c.Check(decoded.Attachments[0].MediaType, Equals, "application/smil")
+	c.Check(decoded.Attachments[0].Data, DeepEquals, mSendReq.Attachments[0].Data)
+	c.Check(decoded.Attachments[1].ContentId, Equals, "<image0>")
+	c.Check(decoded.Attachments[1].Data, DeepEquals, []byte{0xFF, 0xD8})
+	c.Check(string(decoded.Attachments[2].Data), Equals, "hi")
+}
@@ -77,14 +77,17 @@ func (enc *MMSEncoder) Encode(pdu MMSWriter) error {
 		case "Name":
 			err = enc.writeStringParam(WSP_PARAMETER_TYPE_NAME_DEFUNCT, f.String())
 		case "Start":
-			err = enc.writeStringParam(WSP_PARAMETER_TYPE_START_DEFUNCT, f.String())
-		case "To":
-			for i := 0; i < f.Len(); i++ {
-				err = enc.writeStringParam(TO, f.Index(i).String())
-				if err != nil {
-					break
-				}
+			if f.Kind() == reflect.String {
+				err = enc.writeStringParam(WSP_PARAMETER_TYPE_START_DEFUNCT, f.String())
+			} else if start := f.Uint(); start > 0 {
+				err = enc.writeIntegerParam(X_MMS_START, start)
 			}
+		case "To":
+			err = enc.writeAddressList(TO, f)
+		case "Cc":
+			err = enc.writeAddressList(CC, f)
+		case "Bcc":
+			err = enc.writeAddressList(BCC, f)
 		case "ContentType":
 			// if there is a ContentType there has to be content
 			if mSendReq, ok := pdu.(*MSendReq); ok {
@@ -95,6 +98,14 @@ func (enc *MMSEncoder) Encode(pdu MMSWriter) error {
 					return err
 				}
 				err = enc.writeAttachments(mSendReq.Attachments)
+			} else if mMboxUploadReq, ok := pdu.(*MMboxUploadReq); ok {
+				if err := enc.setParam(CONTENT_TYPE); err != nil {
+					return err
+				}
+				if err = enc.writeMediaType(mMboxUploadReq.ContentType); err != nil {
+					return err
+				}
+				err = enc.writeBytes(mMboxUploadReq.Message, len(mMboxUploadReq.Message))
 			} else {
 				err = errors.New("unhandled content type")
 			}
@@ -112,7 +123,15 @@ func (enc *MMSEncoder) Encode(pdu MMSWriter) error {
 			//TODO
 			err = enc.writeCharset(f.String())
 		case "ContentLocation":
-			err = enc.writeStringParam(MMS_PART_CONTENT_LOCATION, f.String())
+			if _, ok := pdu.(*Attachment); ok {
+				err = enc.writeStringParam(MMS_PART_CONTENT_LOCATION, f.String())
+			} else if f.Kind() == reflect.Slice {
+				for i := 0; i < f.Len() && err == nil; i++ {
+					err = enc.writeStringParam(X_MMS_CONTENT_LOCATION, f.Index(i).String())
+				}
+			} else {
+				err = enc.writeStringParam(X_MMS_CONTENT_LOCATION, f.String())
+			}
 		case "ContentId":
 			err = enc.writeQuotedStringParam(MMS_PART_CONTENT_ID, f.String())
 		case "Date":
@@ -128,11 +147,109 @@ func (enc *MMSEncoder) Encode(pdu MMSWriter) error {
 			err = enc.writeByteParam(X_MMS_DELIVERY_REPORT, byte(f.Uint()))
 		case "ReadReport":
 			err = enc.writeByteParam(X_MMS_READ_REPORT, byte(f.Uint()))
+		case "ReadStatus":
+			err = enc.writeByteParam(X_MMS_READ_STATUS, byte(f.Uint()))
+		case "MessageId":
+			err = enc.writeStringParam(MESSAGE_ID, f.String())
+		case "PreviouslySentBy":
+			for _, sentBy := range f.Interface().([]SentBy) {
+				if err = enc.writePreviouslySentBy(sentBy); err != nil {
+					break
+				}
+			}
+		case "PreviouslySentDate":
+			for _, sentDate := range f.Interface().([]SentDate) {
+				if err = enc.writePreviouslySentDate(sentDate); err != nil {
+					break
+				}
+			}
+		case "Subject":
+			var charset string
+			if c := rPdu.FieldByName("SubjectCharset"); c.IsValid() {
+				charset = c.String()
+			}
+			err = enc.writeEncodedStringParam(SUBJECT, f.String(), charset)
 		case "Expiry":
 			expiry := f.Uint()
 			if expiry > 0 {
 				err = enc.writeRelativeExpiry(expiry)
 			}
+		case "DeliveryTime":
+			if deliveryTime := f.Uint(); deliveryTime > 0 {
+				token := ExpiryTokenRelative
+				if absolute := rPdu.FieldByName("DeliveryTimeAbsolute"); absolute.IsValid() && absolute.Bool() {
+					token = ExpiryTokenAbsolute
+				}
+				err = enc.writeTimeParam(X_MMS_DELIVERY_TIME, token, deliveryTime)
+			}
+		case "Priority":
+			if priority := byte(f.Uint()); priority != 0 {
+				err = enc.writeByteParam(X_MMS_PRIORITY, priority)
+			}
+		case "UnknownHeaders":
+			err = enc.writeRawHeaders(f.Interface().([]RawHeader))
+		case "SenderVisibility":
+			if visibility := byte(f.Uint()); visibility != 0 {
+				err = enc.writeByteParam(X_MMS_SENDER_VISIBILITY, visibility)
+			}
+		case "Store":
+			if store := byte(f.Uint()); store != 0 {
+				err = enc.writeByteParam(X_MMS_STORE, store)
+			}
+		case "MMState":
+			if f.Kind() == reflect.Slice {
+				for i := 0; i < f.Len() && err == nil; i++ {
+					err = enc.writeByteParam(X_MMS_MM_STATE, byte(f.Index(i).Uint()))
+				}
+			} else if state := byte(f.Uint()); state != 0 {
+				err = enc.writeByteParam(X_MMS_MM_STATE, state)
+			}
+		case "MMFlags":
+			for _, flag := range f.Interface().([]MMFlag) {
+				if err = enc.writeMMFlag(flag); err != nil {
+					break
+				}
+			}
+		case "Limit":
+			if limit := f.Uint(); limit > 0 {
+				err = enc.writeIntegerParam(X_MMS_LIMIT, limit)
+			}
+		case "Attributes":
+			for i := 0; i < f.Len() && err == nil; i++ {
+				if err = enc.setParam(X_MMS_ATTRIBUTES); err == nil {
+					err = enc.writeShortInteger(f.Index(i).Uint())
+				}
+			}
+		case "Totals":
+			if totals := byte(f.Uint()); totals != 0 {
+				err = enc.writeByteParam(X_MMS_TOTALS, totals)
+			}
+		case "ApplicId":
+			err = enc.writeStringParam(X_MMS_APPLIC_ID, f.String())
+		case "ReplyApplicId":
+			err = enc.writeStringParam(X_MMS_REPLY_APPLIC_ID, f.String())
+		case "AuxApplicInfo":
+			err = enc.writeStringParam(X_MMS_AUX_APPLIC_INFO, f.String())
+		case "ContentClass":
+			if class := byte(f.Uint()); class != 0 {
+				err = enc.writeByteParam(X_MMS_CONTENT_CLASS, class)
+			}
+		case "DrmContent":
+			if drm := byte(f.Uint()); drm != 0 {
+				err = enc.writeByteParam(X_MMS_DRM_CONTENT, drm)
+			}
+		case "AdaptationAllowed":
+			if allowed := byte(f.Uint()); allowed != 0 {
+				err = enc.writeByteParam(X_MMS_ADAPTATION_ALLOWED, allowed)
+			}
+		case "CancelId":
+			err = enc.writeStringParam(X_MMS_CANCEL_ID, f.String())
+		case "CancelStatus":
+			err = enc.writeByteParam(X_MMS_CANCEL_STATUS, byte(f.Uint()))
+		case "Quotas":
+			if quotas := byte(f.Uint()); quotas != 0 {
+				err = enc.writeByteParam(X_MMS_QUOTAS, quotas)
+			}
 		default:
 			if encodeTag == "optional" {
 				log.Printf("Unhandled optional field %s", fieldName)
@@ -284,21 +401,85 @@ func (enc *MMSEncoder) writeMediaType(media string) error {
 }
 
 func (enc *MMSEncoder) writeRelativeExpiry(expiry uint64) error {
-	if err := enc.setParam(X_MMS_EXPIRY); err != nil {
+	return enc.writeTimeParam(X_MMS_EXPIRY, ExpiryTokenRelative, expiry)
+}
+
+// writeTimeParam encodes headers such as X-Mms-Expiry and X-Mms-Delivery-Time
+// which can hold an absolute date or seconds relative to the submission time
+//
+// Value-length (Absolute-token Date-value | Relative-token Delta-seconds-value)
+func (enc *MMSEncoder) writeTimeParam(param, token byte, t uint64) error {
+	if err := enc.setParam(param); err != nil {
 		return err
 	}
-	encodedLong := encodeLong(expiry)
+	encodedLong := encodeLong(t)
 
 	var b []byte
 	// +1 for the token, +1 for the len of long
 	b = append(b, byte(len(encodedLong)+2))
-	b = append(b, ExpiryTokenRelative)
+	b = append(b, token)
 	b = append(b, byte(len(encodedLong)))
 	b = append(b, encodedLong...)
 
 	return enc.writeBytes(b, len(b))
 }
 
+// writePreviouslySentBy encodes a X-Mms-Previously-Sent-By header
+//
+// Previously-sent-by-value = Value-length Forwarded-count-value Encoded-string-value
+func (enc *MMSEncoder) writePreviouslySentBy(sentBy SentBy) error {
+	var b bytes.Buffer
+	valueEnc := NewEncoder(&b)
+	if err := valueEnc.writeInteger(sentBy.Count); err != nil {
+		return err
+	}
+	if err := valueEnc.writeString(sentBy.Address); err != nil {
+		return err
+	}
+	return enc.writeValueParam(X_MMS_PREVIOUSLY_SENT_BY, b.Bytes())
+}
+
+// writePreviouslySentDate encodes a X-Mms-Previously-Sent-Date header
+//
+// Previously-sent-date-value = Value-length Forwarded-count-value Date-value
+func (enc *MMSEncoder) writePreviouslySentDate(sentDate SentDate) error {
+	var b bytes.Buffer
+	valueEnc := NewEncoder(&b)
+	if err := valueEnc.writeInteger(sentDate.Count); err != nil {
+		return err
+	}
+	if err := valueEnc.writeLongInteger(sentDate.Date); err != nil {
+		return err
+	}
+	return enc.writeValueParam(X_MMS_PREVIOUSLY_SENT_DATE, b.Bytes())
+}
+
+// writeMMFlag encodes a X-Mms-MM-Flags header
+//
+// MM-flags-value = Value-length ( Add-token | Remove-token | Filter-token ) Encoded-string-value
+func (enc *MMSEncoder) writeMMFlag(flag MMFlag) error {
+	var b bytes.Buffer
+	valueEnc := NewEncoder(&b)
+	if err := valueEnc.writeByte(flag.Token); err != nil {
+		return err
+	}
+	if err := valueEnc.writeString(flag.Keyword); err != nil {
+		return err
+	}
+	return enc.writeValueParam(X_MMS_MM_FLAGS, b.Bytes())
+}
+
+// writeValueParam writes param followed by the Value-length of v and v
+func (enc *MMSEncoder) writeValueParam(param byte, v []byte) error {
+	if err := enc.setParam(param); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(len(v))); err != nil {
+		return err
+	}
+	return enc.writeBytes(v, len(v))
+}
+
 func (enc *MMSEncoder) writeLongIntegerParam(param byte, i uint64) error {
 	if err := enc.setParam(param); err != nil {
 		return err
@@ -337,6 +518,48 @@ func (enc *MMSEncoder) writeStringParam(param byte, s string) error {
 	return enc.writeString(s)
 }
 
+// writeEncodedStringParam writes s as an Encoded-string-value converted to
+// charset, a Text-string is used when no charset is given and s is plain
+// ASCII and UTF-8 is used for any other text without a charset.
+//
+// Encoded-string-value = Text-string | Value-length Char-set Text-string
+func (enc *MMSEncoder) writeEncodedStringParam(param byte, s, charset string) error {
+	if s == "" {
+		enc.log = enc.log + "Skipping empty string\n"
+		return nil
+	}
+	if charset == "" {
+		if isASCII(s) {
+			return enc.writeStringParam(param, s)
+		}
+		charset = "utf-8"
+	}
+	charsetCode, ok := getCharsetCode(charset)
+	if !ok {
+		return fmt.Errorf("unsupported charset %s", charset)
+	}
+	text, err := EncodeCharset(charset, s)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	valueEnc := NewEncoder(&b)
+	if err := valueEnc.writeInteger(charsetCode); err != nil {
+		return err
+	}
+	if len(text) > 0 && text[0] >= 0x80 {
+		if err := valueEnc.writeByte(TEXT_QUOTE); err != nil {
+			return err
+		}
+	}
+	text = append(text, 0)
+	if err := valueEnc.writeBytes(text, len(text)); err != nil {
+		return err
+	}
+	return enc.writeValueParam(param, b.Bytes())
+}
+
 func (enc *MMSEncoder) writeByteParam(param byte, b byte) error {
 	if err := enc.setParam(param); err != nil {
 		return err
@@ -344,6 +567,35 @@ func (enc *MMSEncoder) writeByteParam(param byte, b byte) error {
 	return enc.writeByte(b)
 }
 
+// writeAddressList writes an Encoded-string-value param header for each
+// address in addresses
+func (enc *MMSEncoder) writeAddressList(param byte, addresses reflect.Value) error {
+	for i := 0; i < addresses.Len(); i++ {
+		if err := enc.writeEncodedStringParam(param, addresses.Index(i).String(), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRawHeaders writes headers back as they were decoded
+func (enc *MMSEncoder) writeRawHeaders(headers []RawHeader) error {
+	for _, header := range headers {
+		var err error
+		if header.Name != "" {
+			if err = enc.writeString(header.Name); err == nil {
+				err = enc.writeString(string(header.Value))
+			}
+		} else if err = enc.setParam(header.Code); err == nil {
+			err = enc.writeBytes(header.Value, len(header.Value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (enc *MMSEncoder) writeFrom() error {
 	if err := enc.setParam(FROM); err != nil {
 		return err
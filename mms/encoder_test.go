@@ -131,11 +131,157 @@ func (s *EncoderTestSuite) TestEncodeMSendReq(c *C) {
 
 	attachments := []*Attachment{att}
 
-	recipients := []string{"+12345"}
-	mSendReq := NewMSendReq(recipients, attachments, false)
+	recipients := []Address{{Type: AddressPLMN, Value: "+12345"}}
+	mSendReq := NewMSendReq(recipients, attachments, false, false)
 
 	var outBytes bytes.Buffer
 	enc := NewEncoder(&outBytes)
 	err = enc.Encode(mSendReq)
 	c.Assert(err, IsNil)
 }
+
+func (s *EncoderTestSuite) TestEncodeMReadRecInd(c *C) {
+	expectedBytes := []byte{
+		//Message Type m-read-rec.ind
+		0x8C, 0x87,
+		// MMS Version 1.1
+		0x8D, 0x91,
+		// Message Id "0123456"
+		0x8B, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// To "+12345/TYPE=PLMN"
+		0x97, 0x2b, 0x31, 0x32, 0x33, 0x34, 0x35, 0x2f, 0x54, 0x59, 0x50, 0x45, 0x3d, 0x50, 0x4c, 0x4d, 0x4e, 0x00,
+		// From insert address
+		0x89, 0x01, 0x81,
+		// Read Status read
+		0x9B, 0x80,
+	}
+	mRetrieveConf := &MRetrieveConf{
+		UUID:       "1",
+		MessageId:  "0123456",
+		From:       "+12345/TYPE=PLMN",
+		ReadReport: ReadReportYes,
+	}
+	mReadRecInd := mRetrieveConf.NewMReadRecInd(ReadStatusRead)
+	c.Check(mReadRecInd.UUID, Equals, "1")
+	mReadRecInd.Date = 0
+	var outBytes bytes.Buffer
+	enc := NewEncoder(&outBytes)
+	c.Assert(enc.Encode(mReadRecInd), IsNil)
+	c.Assert(outBytes.Bytes(), DeepEquals, expectedBytes)
+}
+
+func (s *EncoderTestSuite) TestEncodeMAcknowledgeInd(c *C) {
+	expectedBytes := []byte{
+		//Message Type m-acknowledge.ind
+		0x8C, 0x85,
+		// Transaction Id
+		0x98, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// MMS Version 1.3
+		0x8D, 0x93,
+		// Report Allowed Yes
+		0x91, 0x80,
+	}
+	mRetrieveConf := &MRetrieveConf{
+		UUID:          "1",
+		TransactionId: "0123456",
+		Version:       MMS_MESSAGE_VERSION_1_3,
+	}
+	mAcknowledgeInd := mRetrieveConf.NewMAcknowledgeInd(true)
+	c.Check(mAcknowledgeInd.Type, Equals, byte(TYPE_ACKNOWLEDGE_IND))
+	var outBytes bytes.Buffer
+	enc := NewEncoder(&outBytes)
+	c.Assert(enc.Encode(mAcknowledgeInd), IsNil)
+	c.Assert(outBytes.Bytes(), DeepEquals, expectedBytes)
+}
+
+func (s *EncoderTestSuite) TestEncodeMCancelConf(c *C) {
+	expectedBytes := []byte{
+		//Message Type m-cancel.conf
+		0x8C, 0x97,
+		// Transaction Id
+		0x98, 0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x00,
+		// MMS Version 1.3
+		0x8D, 0x93,
+		// Cancel Status Cancel Request Successfully received
+		0xBF, 0x80,
+	}
+	mCancelReq := &MCancelReq{
+		Type:          TYPE_CANCEL_REQ,
+		TransactionId: "0123456",
+		CancelId:      "http://mmsc.example.com/1",
+	}
+	mCancelConf := mCancelReq.NewMCancelConf(CancelStatusReceived)
+	var outBytes bytes.Buffer
+	enc := NewEncoder(&outBytes)
+	c.Assert(enc.Encode(mCancelConf), IsNil)
+	c.Assert(outBytes.Bytes(), DeepEquals, expectedBytes)
+}
+
+func (s *EncoderTestSuite) TestEncodeMSendReqOptionalHeaders(c *C) {
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+12345"}}, []*Attachment{}, false, false)
+	mSendReq.Subject = "Hello"
+	mSendReq.Priority = PriorityHigh
+	mSendReq.SenderVisibility = SenderVisibilityHide
+	mSendReq.DeliveryTime = 3600
+
+	var outBytes bytes.Buffer
+	enc := NewEncoder(&outBytes)
+	c.Assert(enc.Encode(mSendReq), IsNil)
+	encoded := outBytes.Bytes()
+
+	expectedHeaders := [][]byte{
+		// Subject "Hello"
+		{0x96, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x00},
+		// Delivery Time relative 3600 seconds
+		{0x87, 0x04, 0x81, 0x02, 0x0E, 0x10},
+		// Priority High
+		{0x8F, 0x82},
+		// Sender Visibility Hide
+		{0x94, 0x80},
+	}
+	for _, header := range expectedHeaders {
+		c.Check(bytes.Contains(encoded, header), Equals, true, Commentf("%#x not in %#x", header, encoded))
+	}
+}
+
+func (s *EncoderTestSuite) TestEncodeMSendReqMMS13Headers(c *C) {
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+12345"}}, []*Attachment{}, false, false)
+	mSendReq.ContentClass = ContentClassImageBasic
+	mSendReq.DrmContent = DrmContentNo
+	mSendReq.AdaptationAllowed = AdaptationAllowedYes
+	mSendReq.ApplicId = "app"
+	mSendReq.ReplyApplicId = "rep"
+
+	var outBytes bytes.Buffer
+	enc := NewEncoder(&outBytes)
+	c.Assert(enc.Encode(mSendReq), IsNil)
+	encoded := outBytes.Bytes()
+
+	expectedHeaders := [][]byte{
+		// Content Class image-basic
+		{0xBA, 0x81},
+		// DRM Content No
+		{0xBB, 0x81},
+		// Adaptation Allowed Yes
+		{0xBC, 0x80},
+		// Applic ID "app"
+		{0xB7, 0x61, 0x70, 0x70, 0x00},
+		// Reply Applic ID "rep"
+		{0xB8, 0x72, 0x65, 0x70, 0x00},
+	}
+	for _, header := range expectedHeaders {
+		c.Check(bytes.Contains(encoded, header), Equals, true, Commentf("%#x not in %#x", header, encoded))
+	}
+}
+
+func (s *EncoderTestSuite) TestEncodeAbsoluteDeliveryTime(c *C) {
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+12345"}}, []*Attachment{}, false, false)
+	mSendReq.DeliveryTime = 0x545ac037
+	mSendReq.DeliveryTimeAbsolute = true
+
+	var outBytes bytes.Buffer
+	enc := NewEncoder(&outBytes)
+	c.Assert(enc.Encode(mSendReq), IsNil)
+	header := []byte{0x87, 0x06, 0x80, 0x04, 0x54, 0x5a, 0xc0, 0x37}
+	c.Check(bytes.Contains(outBytes.Bytes(), header), Equals, true, Commentf("%#x not in %#x", header, outBytes.Bytes()))
+}
@@ -22,6 +22,7 @@
 package mms
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
@@ -65,6 +66,28 @@ const (
 	X_MMS_REPLY_CHARGING_SIZE     = 0x1F
 	X_MMS_PREVIOUSLY_SENT_BY      = 0x20
 	X_MMS_PREVIOUSLY_SENT_DATE    = 0x21
+	X_MMS_STORE                   = 0x22
+	X_MMS_MM_STATE                = 0x23
+	X_MMS_MM_FLAGS                = 0x24
+	X_MMS_STORE_STATUS            = 0x25
+	X_MMS_STORE_STATUS_TEXT       = 0x26
+	X_MMS_STORED                  = 0x27
+	X_MMS_ATTRIBUTES              = 0x28
+	X_MMS_TOTALS                  = 0x29
+	X_MMS_MBOX_TOTALS             = 0x2A
+	X_MMS_QUOTAS                  = 0x2B
+	X_MMS_MBOX_QUOTAS             = 0x2C
+	X_MMS_MESSAGE_COUNT           = 0x2D
+	X_MMS_START                   = 0x2F
+	X_MMS_LIMIT                   = 0x33
+	X_MMS_APPLIC_ID               = 0x37
+	X_MMS_REPLY_APPLIC_ID         = 0x38
+	X_MMS_AUX_APPLIC_INFO         = 0x39
+	X_MMS_CONTENT_CLASS           = 0x3A
+	X_MMS_DRM_CONTENT             = 0x3B
+	X_MMS_ADAPTATION_ALLOWED      = 0x3C
+	X_MMS_CANCEL_ID               = 0x3E
+	X_MMS_CANCEL_STATUS           = 0x3F
 )
 
 // MMS Content Type Assignments OMA-WAP-MMS section 7.3 Table 13
@@ -81,6 +104,21 @@ const (
 	TYPE_RETRIEVE_CONF    = 0x84
 	TYPE_ACKNOWLEDGE_IND  = 0x85
 	TYPE_DELIVERY_IND     = 0x86
+	TYPE_READ_REC_IND     = 0x87
+	TYPE_READ_ORIG_IND    = 0x88
+	TYPE_FORWARD_REQ      = 0x89
+	TYPE_FORWARD_CONF     = 0x8A
+	TYPE_MBOX_STORE_REQ   = 0x8B
+	TYPE_MBOX_STORE_CONF  = 0x8C
+	TYPE_MBOX_VIEW_REQ    = 0x8D
+	TYPE_MBOX_VIEW_CONF   = 0x8E
+	TYPE_MBOX_UPLOAD_REQ  = 0x8F
+	TYPE_MBOX_UPLOAD_CONF = 0x90
+	TYPE_MBOX_DELETE_REQ  = 0x91
+	TYPE_MBOX_DELETE_CONF = 0x92
+	TYPE_MBOX_DESCR       = 0x93
+	TYPE_CANCEL_REQ       = 0x96
+	TYPE_CANCEL_CONF      = 0x97
 )
 
 const (
@@ -102,6 +140,19 @@ const (
 	ExpiryTokenRelative byte = 129
 )
 
+// X-Mms-Priority values defined in OMA-WAP-MMS-ENC
+const (
+	PriorityLow    byte = 128
+	PriorityNormal byte = 129
+	PriorityHigh   byte = 130
+)
+
+// X-Mms-Sender-Visibility values defined in OMA-WAP-MMS-ENC
+const (
+	SenderVisibilityHide byte = 128
+	SenderVisibilityShow byte = 129
+)
+
 // From tokens defined in OMA-WAP-MMS section 7.2.11
 const (
 	TOKEN_ADDRESS_PRESENT = 0x80
@@ -122,6 +173,12 @@ const (
 	ReadReportNo  byte = 129
 )
 
+// Read Status defined in OMA-WAP-MMS section 7.2.22
+const (
+	ReadStatusRead    byte = 128
+	ReadStatusDeleted byte = 129
+)
+
 // Report Allowed defined in OMA-WAP-MMS section 7.2.26
 const (
 	ReportAllowedYes byte = 128
@@ -173,48 +230,147 @@ const (
 
 // Status defined in OMA-WAP-MMS section 7.2.23
 const (
-	STATUS_EXPIRED      = 128
-	STATUS_RETRIEVED    = 129
-	STATUS_REJECTED     = 130
-	STATUS_DEFERRED     = 131
-	STATUS_UNRECOGNIZED = 132
+	STATUS_EXPIRED       = 128
+	STATUS_RETRIEVED     = 129
+	STATUS_REJECTED      = 130
+	STATUS_DEFERRED      = 131
+	STATUS_UNRECOGNIZED  = 132
+	STATUS_INDETERMINATE = 133
+	STATUS_FORWARDED     = 134
+	STATUS_UNREACHABLE   = 135
+)
+
+// X-Mms-Content-Class values defined in OMA-MMS-ENC-v1.3 section 7.3.9
+const (
+	ContentClassText         byte = 128
+	ContentClassImageBasic   byte = 129
+	ContentClassImageRich    byte = 130
+	ContentClassVideoBasic   byte = 131
+	ContentClassVideoRich    byte = 132
+	ContentClassMegaPixel    byte = 133
+	ContentClassContentBasic byte = 134
+	ContentClassContentRich  byte = 135
+)
+
+// X-Mms-DRM-Content and X-Mms-Adaptation-Allowed values defined in
+// OMA-MMS-ENC-v1.3 sections 7.3.16 and 7.3.2
+const (
+	DrmContentYes        byte = 128
+	DrmContentNo         byte = 129
+	AdaptationAllowedYes byte = 128
+	AdaptationAllowedNo  byte = 129
 )
 
+// X-Mms-Cancel-Status values defined in OMA-MMS-ENC-v1.3 section 7.3.8
+const (
+	CancelStatusReceived  byte = 128
+	CancelStatusCorrupted byte = 129
+)
+
+// X-Mms-Store, X-Mms-Stored, X-Mms-Totals and X-Mms-Quotas values defined in
+// OMA-MMS-ENC-v1.2 section 7.3
+const (
+	MboxYes byte = 128
+	MboxNo  byte = 129
+)
+
+// X-Mms-MM-State values defined in OMA-MMS-ENC-v1.2 section 7.3.33
+const (
+	MMStateDraft     byte = 128
+	MMStateSent      byte = 129
+	MMStateNew       byte = 130
+	MMStateRetrieved byte = 131
+	MMStateForwarded byte = 132
+)
+
+// X-Mms-MM-Flags tokens defined in OMA-MMS-ENC-v1.2 section 7.3.32
+const (
+	MMFlagsAdd    byte = 128
+	MMFlagsRemove byte = 129
+	MMFlagsFilter byte = 130
+)
+
+// X-Mms-Store-Status values defined in OMA-MMS-ENC-v1.2 section 7.3.58
+const (
+	StoreStatusSuccess                            byte = 128
+	StoreStatusErrorTransientFailure              byte = 192
+	StoreStatusErrorTransientNetworkProblem       byte = 193
+	StoreStatusErrorPermanentFailure              byte = 224
+	StoreStatusErrorPermanentServiceDenied        byte = 225
+	StoreStatusErrorPermanentMessageFormatCorrupt byte = 226
+	StoreStatusErrorPermanentMessageNotFound      byte = 227
+	StoreStatusErrorPermanentMMBoxFull            byte = 228
+)
+
+// X-Mms-Mbox-Totals and X-Mms-Mbox-Quotas tokens defined in
+// OMA-MMS-ENC-v1.2 sections 7.3.28 and 7.3.29
+const (
+	MboxCountMessages byte = 128
+	MboxCountSize     byte = 129
+)
+
+// RawHeader holds a header that has no field in a PDU, it is kept as received
+// so it can be inspected and encoded again. Application headers are the ones
+// with a Name, the rest are well known headers identified by Code.
+type RawHeader struct {
+	Code byte
+	Name string
+	// Value is the encoded field value for well known headers and the
+	// text for application headers.
+	Value []byte
+}
+
 // MSendReq holds a m-send.req message defined in
 // OMA-WAP-MMS-ENC-v1.1 section 6.1.1
 type MSendReq struct {
-	UUID             string `encode:"no"`
-	Type             byte
-	TransactionId    string
-	Version          byte
-	Date             uint64 `encode:"optional"`
-	From             string
-	To               []string
-	Cc               string `encode:"no"`
-	Bcc              string `encode:"no"`
-	Subject          string `encode:"optional"`
-	Class            byte   `encode:"optional"`
-	Expiry           uint64 `encode:"optional"`
-	DeliveryTime     uint64 `encode:"optional"`
-	Priority         byte   `encode:"optional"`
-	SenderVisibility byte   `encode:"optional"`
-	DeliveryReport   byte   `encode:"optional"`
-	ReadReport       byte   `encode:"optional"`
-	ContentTypeStart string `encode:"no"`
-	ContentTypeType  string `encode:"no"`
-	ContentType      string
-	Attachments      []*Attachment `encode:"no"`
+	UUID                 string `encode:"no"`
+	Type                 byte
+	TransactionId        string
+	Version              byte
+	Date                 uint64 `encode:"optional"`
+	From                 string
+	To                   []string
+	Cc                   []string    `encode:"optional"`
+	Bcc                  []string    `encode:"optional"`
+	Subject              string      `encode:"optional"`
+	SubjectCharset       string      `encode:"no"`
+	Class                byte        `encode:"optional"`
+	Expiry               uint64      `encode:"optional"`
+	DeliveryTime         uint64      `encode:"optional"`
+	DeliveryTimeAbsolute bool        `encode:"no"`
+	Priority             byte        `encode:"optional"`
+	SenderVisibility     byte        `encode:"optional"`
+	DeliveryReport       byte        `encode:"optional"`
+	ReadReport           byte        `encode:"optional"`
+	Store                byte        `encode:"optional"`
+	MMState              byte        `encode:"optional"`
+	MMFlags              []MMFlag    `encode:"optional"`
+	ContentClass         byte        `encode:"optional"`
+	DrmContent           byte        `encode:"optional"`
+	AdaptationAllowed    byte        `encode:"optional"`
+	ApplicId             string      `encode:"optional"`
+	ReplyApplicId        string      `encode:"optional"`
+	AuxApplicInfo        string      `encode:"optional"`
+	UnknownHeaders       []RawHeader `encode:"optional"`
+	ContentTypeStart     string      `encode:"no"`
+	ContentTypeType      string      `encode:"no"`
+	ContentType          string
+	Attachments          []*Attachment `encode:"no"`
 }
 
 // MSendReq holds a m-send.conf message defined in
 // OMA-WAP-MMS-ENC section 6.1.2
 type MSendConf struct {
-	Type           byte
-	TransactionId  string
-	Version        byte
-	ResponseStatus byte
-	ResponseText   string
-	MessageId      string
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ResponseStatus  byte
+	ResponseText    string
+	MessageId       string
+	ContentLocation string
+	StoreStatus     byte
+	StoreStatusText string
+	UnknownHeaders  []RawHeader
 }
 
 // MNotificationInd holds a m-notification.ind message defined in
@@ -229,17 +385,35 @@ type MNotificationInd struct {
 	TransactionId, ContentLocation       string
 	From, Subject                        string
 	Expiry, Size                         uint64
+	Stored                               byte
+	ContentClass, DrmContent             byte
+	AdaptationAllowed                    byte
+	ApplicId, ReplyApplicId              string
+	AuxApplicInfo                        string
+	UnknownHeaders                       []RawHeader
 }
 
 // MNotificationInd holds a m-notifyresp.ind message defined in
 // OMA-WAP-MMS-ENC-v1.1 section 6.2
 type MNotifyRespInd struct {
-	UUID          string `encode:"no"`
-	Type          byte
-	TransactionId string
-	Version       byte
-	Status        byte
-	ReportAllowed byte `encode:"optional"`
+	UUID           string `encode:"no"`
+	Type           byte
+	TransactionId  string
+	Version        byte
+	Status         byte
+	ReportAllowed  byte        `encode:"optional"`
+	UnknownHeaders []RawHeader `encode:"optional"`
+}
+
+// MAcknowledgeInd holds a m-acknowledge.ind message defined in
+// OMA-WAP-MMS-ENC-v1.1 section 6.4
+type MAcknowledgeInd struct {
+	UUID           string `encode:"no"`
+	Type           byte
+	TransactionId  string
+	Version        byte
+	ReportAllowed  byte        `encode:"optional"`
+	UnknownHeaders []RawHeader `encode:"optional"`
 }
 
 // MRetrieveConf holds a m-retrieve.conf message defined in
@@ -252,30 +426,320 @@ type MRetrieveConf struct {
 	ReplyChargingId                            string
 	ReadReport, RetrieveStatus, DeliveryReport byte
 	TransactionId, MessageId, RetrieveText     string
-	From, Cc, Subject                          string
-	To                                         []string
+	From, Subject                              string
+	To, Cc, Bcc                                []string
 	ReportAllowed                              byte
 	Date                                       uint64
+	PreviouslySentBy                           []SentBy
+	PreviouslySentDate                         []SentDate
+	MMState                                    byte
+	MMFlags                                    []MMFlag
+	ContentClass, DrmContent                   byte
+	AdaptationAllowed                          byte
+	ApplicId, ReplyApplicId                    string
+	AuxApplicInfo                              string
+	UnknownHeaders                             []RawHeader
 	Content                                    Attachment
 	Attachments                                []Attachment
 	Data                                       []byte
 }
 
+// MDeliveryInd holds a m-delivery.ind message defined in
+// OMA-WAP-MMS-ENC-v1.1 section 6.6
+type MDeliveryInd struct {
+	MMSReader
+	UUID           string
+	Type           byte
+	Version        byte
+	MessageId      string
+	To             []string
+	Date           uint64
+	Status         byte
+	UnknownHeaders []RawHeader
+}
+
+// MReadRecInd holds a m-read-rec.ind message defined in
+// OMA-WAP-MMS-ENC-v1.1 section 6.7.2
+type MReadRecInd struct {
+	UUID           string `encode:"no"`
+	Type           byte
+	Version        byte
+	MessageId      string
+	To             []string
+	From           string
+	Date           uint64 `encode:"optional"`
+	ReadStatus     byte
+	UnknownHeaders []RawHeader `encode:"optional"`
+}
+
+// MReadOrigInd holds a m-read-orig.ind message defined in
+// OMA-WAP-MMS-ENC-v1.1 section 6.7.2
+type MReadOrigInd struct {
+	MMSReader
+	UUID           string
+	Type           byte
+	Version        byte
+	MessageId      string
+	To             []string
+	From           string
+	Date           uint64
+	ReadStatus     byte
+	UnknownHeaders []RawHeader
+}
+
+// MForwardReq holds a m-forward.req message defined in
+// OMA-WAP-MMS-ENC-v1.2 section 6.5.2
+type MForwardReq struct {
+	UUID               string `encode:"no"`
+	Type               byte
+	TransactionId      string
+	Version            byte
+	Date               uint64 `encode:"optional"`
+	From               string
+	To                 []string
+	Cc                 []string    `encode:"optional"`
+	Bcc                []string    `encode:"optional"`
+	Expiry             uint64      `encode:"optional"`
+	DeliveryReport     byte        `encode:"optional"`
+	ReadReport         byte        `encode:"optional"`
+	PreviouslySentBy   []SentBy    `encode:"optional"`
+	PreviouslySentDate []SentDate  `encode:"optional"`
+	Store              byte        `encode:"optional"`
+	MMState            byte        `encode:"optional"`
+	MMFlags            []MMFlag    `encode:"optional"`
+	UnknownHeaders     []RawHeader `encode:"optional"`
+	ContentLocation    string
+}
+
+// MForwardConf holds a m-forward.conf message defined in
+// OMA-WAP-MMS-ENC-v1.2 section 6.5.3
+type MForwardConf struct {
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ResponseStatus  byte
+	ResponseText    string
+	MessageId       string
+	ContentLocation string
+	StoreStatus     byte
+	StoreStatusText string
+	UnknownHeaders  []RawHeader
+}
+
+// MCancelReq holds a m-cancel.req message defined in
+// OMA-MMS-ENC-v1.3 section 6.14.1
+type MCancelReq struct {
+	Type           byte
+	TransactionId  string
+	Version        byte
+	CancelId       string
+	UnknownHeaders []RawHeader
+}
+
+// MCancelConf holds a m-cancel.conf message defined in
+// OMA-MMS-ENC-v1.3 section 6.14.2
+type MCancelConf struct {
+	UUID           string `encode:"no"`
+	Type           byte
+	TransactionId  string
+	Version        byte
+	CancelStatus   byte
+	UnknownHeaders []RawHeader `encode:"optional"`
+}
+
+// SentBy holds a X-Mms-Previously-Sent-By value, Count is the position of
+// Address in the chain of forwards of the message.
+//
+// Previously-sent-by-value = Value-length Forwarded-count-value Encoded-string-value
+type SentBy struct {
+	Count   uint64
+	Address string
+}
+
+// SentDate holds a X-Mms-Previously-Sent-Date value, Count matches the one
+// of the SentBy for the same forward.
+//
+// Previously-sent-date-value = Value-length Forwarded-count-value Date-value
+type SentDate struct {
+	Count uint64
+	Date  uint64
+}
+
+// MMFlag holds a X-Mms-MM-Flags value, Token tells if Keyword is added to,
+// removed from or used to filter the messages in the MMBox.
+//
+// MM-flags-value = Value-length ( Add-token | Remove-token | Filter-token ) Encoded-string-value
+type MMFlag struct {
+	Token   byte
+	Keyword string
+}
+
+// MboxCount holds a X-Mms-Mbox-Totals or X-Mms-Mbox-Quotas value, Token tells
+// if Value is a number of messages or a size in octets.
+//
+// Mbox-totals-value = Value-length ( Message-total-token | Size-total-token ) Integer-Value
+type MboxCount struct {
+	Token byte
+	Value uint64
+}
+
+// MMboxStoreReq holds a m-mbox-store.req message defined in
+// OMA-MMS-ENC-v1.2 section 6.8.1
+type MMboxStoreReq struct {
+	UUID            string `encode:"no"`
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ContentLocation string
+	MMState         byte        `encode:"optional"`
+	MMFlags         []MMFlag    `encode:"optional"`
+	UnknownHeaders  []RawHeader `encode:"optional"`
+}
+
+// MMboxStoreConf holds a m-mbox-store.conf message defined in
+// OMA-MMS-ENC-v1.2 section 6.8.2
+type MMboxStoreConf struct {
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ContentLocation string
+	StoreStatus     byte
+	StoreStatusText string
+	UnknownHeaders  []RawHeader
+}
+
+// MMboxViewReq holds a m-mbox-view.req message defined in
+// OMA-MMS-ENC-v1.2 section 6.9.1, Attributes lists the header field codes to
+// be returned for each message.
+type MMboxViewReq struct {
+	UUID            string `encode:"no"`
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ContentLocation []string    `encode:"optional"`
+	MMState         []byte      `encode:"optional"`
+	MMFlags         []MMFlag    `encode:"optional"`
+	Start           uint64      `encode:"optional"`
+	Limit           uint64      `encode:"optional"`
+	Attributes      []byte      `encode:"optional"`
+	Totals          byte        `encode:"optional"`
+	Quotas          byte        `encode:"optional"`
+	UnknownHeaders  []RawHeader `encode:"optional"`
+}
+
+// MMboxViewConf holds a m-mbox-view.conf message defined in
+// OMA-MMS-ENC-v1.2 section 6.9.2, each of its Attachments holds a
+// m-mbox-descr which can be decoded with Descriptions.
+type MMboxViewConf struct {
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ResponseStatus  byte
+	ResponseText    string
+	ContentLocation []string
+	MMState         []byte
+	MMFlags         []MMFlag
+	Start           uint64
+	Limit           uint64
+	Attributes      []byte
+	MboxTotals      []MboxCount
+	MboxQuotas      []MboxCount
+	MessageCount    uint64
+	UnknownHeaders  []RawHeader
+	Content         Attachment
+	Attachments     []Attachment
+}
+
+// MMboxUploadReq holds a m-mbox-upload.req message defined in
+// OMA-MMS-ENC-v1.2 section 6.10.1, Message is the encoded m-mbox-descr
+// being uploaded.
+type MMboxUploadReq struct {
+	UUID           string `encode:"no"`
+	Type           byte
+	TransactionId  string
+	Version        byte
+	MMState        byte        `encode:"optional"`
+	MMFlags        []MMFlag    `encode:"optional"`
+	UnknownHeaders []RawHeader `encode:"optional"`
+	ContentType    string
+	Message        []byte `encode:"no"`
+}
+
+// MMboxUploadConf holds a m-mbox-upload.conf message defined in
+// OMA-MMS-ENC-v1.2 section 6.10.2
+type MMboxUploadConf struct {
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ContentLocation string
+	StoreStatus     byte
+	StoreStatusText string
+	UnknownHeaders  []RawHeader
+}
+
+// MMboxDeleteReq holds a m-mbox-delete.req message defined in
+// OMA-MMS-ENC-v1.2 section 6.11.1
+type MMboxDeleteReq struct {
+	UUID            string `encode:"no"`
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ContentLocation []string
+	UnknownHeaders  []RawHeader `encode:"optional"`
+}
+
+// MMboxDeleteConf holds a m-mbox-delete.conf message defined in
+// OMA-MMS-ENC-v1.2 section 6.11.2, the n-th entries of ContentLocation,
+// ResponseStatus and ResponseText refer to the same message.
+type MMboxDeleteConf struct {
+	Type            byte
+	TransactionId   string
+	Version         byte
+	ContentLocation []string
+	ResponseStatus  []byte
+	ResponseText    []string
+	UnknownHeaders  []RawHeader
+}
+
+// MMboxDescr holds a m-mbox-descr message defined in
+// OMA-MMS-ENC-v1.2 section 6.12
+type MMboxDescr struct {
+	Type                 byte
+	Version              byte
+	ContentLocation      string
+	MessageId            string
+	MMState              byte
+	MMFlags              []MMFlag
+	Date                 uint64
+	From, Subject        string
+	To, Cc, Bcc          []string
+	Class, Priority      byte
+	DeliveryTime, Expiry uint64
+	DeliveryReport       byte
+	ReadReport           byte
+	Size                 uint64
+	ReplyCharging        byte
+	ReplyChargingId      string
+	PreviouslySentBy     []SentBy
+	PreviouslySentDate   []SentDate
+	UnknownHeaders       []RawHeader
+	Content              Attachment
+	Attachments          []Attachment
+	Data                 []byte
+}
+
 type MMSReader interface{}
 type MMSWriter interface{}
 
-// NewMSendReq creates a personal message with a normal priority and no read report
-func NewMSendReq(recipients []string, attachments []*Attachment, deliveryReport bool) *MSendReq {
-	for i := range recipients {
-		recipients[i] += "/TYPE=PLMN"
-	}
+// NewMSendReq creates a personal message with a normal priority
+func NewMSendReq(recipients []Address, attachments []*Attachment, deliveryReport, readReport bool) *MSendReq {
 	uuid := genUUID()
 
 	orderedAttachments, smilStart, smilType := processAttachments(attachments)
 
 	return &MSendReq{
 		Type:          TYPE_SEND_REQ,
-		To:            recipients,
+		To:            formatAddresses(recipients),
 		TransactionId: uuid,
 		Version:       MMS_MESSAGE_VERSION_1_1,
 		UUID:          uuid,
@@ -283,7 +747,7 @@ func NewMSendReq(recipients []string, attachments []*Attachment, deliveryReport
 		// this will expire the message in 7 days
 		Expiry:           uint64(time.Duration(time.Hour * 24 * 7).Seconds()),
 		DeliveryReport:   getDeliveryReport(deliveryReport),
-		ReadReport:       ReadReportNo,
+		ReadReport:       getReadReport(readReport),
 		Class:            ClassPersonal,
 		ContentType:      "application/vnd.wap.multipart.related",
 		ContentTypeStart: smilStart,
@@ -292,6 +756,16 @@ func NewMSendReq(recipients []string, attachments []*Attachment, deliveryReport
 	}
 }
 
+//SetCc sets the carbon copy recipients for mSendReq
+func (mSendReq *MSendReq) SetCc(recipients []Address) {
+	mSendReq.Cc = formatAddresses(recipients)
+}
+
+//SetBcc sets the blind carbon copy recipients for mSendReq
+func (mSendReq *MSendReq) SetBcc(recipients []Address) {
+	mSendReq.Bcc = formatAddresses(recipients)
+}
+
 func NewMSendConf() *MSendConf {
 	return &MSendConf{
 		Type: TYPE_SEND_CONF,
@@ -328,14 +802,221 @@ func (mRetrieveConf *MRetrieveConf) NewMNotifyRespInd(deliveryReport bool) *MNot
 	}
 }
 
+//NewMAcknowledgeInd creates the response to a m-retrieve.conf obtained
+//through deferred retrieval, the immediate retrieval case is answered with
+//a m-notifyresp.ind instead.
+func (mRetrieveConf *MRetrieveConf) NewMAcknowledgeInd(deliveryReport bool) *MAcknowledgeInd {
+	return &MAcknowledgeInd{
+		Type:          TYPE_ACKNOWLEDGE_IND,
+		UUID:          mRetrieveConf.UUID,
+		TransactionId: mRetrieveConf.TransactionId,
+		Version:       mRetrieveConf.Version,
+		ReportAllowed: getReportAllowed(deliveryReport),
+	}
+}
+
 func NewMNotifyRespInd() *MNotifyRespInd {
 	return &MNotifyRespInd{Type: TYPE_NOTIFYRESP_IND}
 }
 
+//NewMReadRecInd creates the read report to send back to the originator of
+//mRetrieveConf
+func (mRetrieveConf *MRetrieveConf) NewMReadRecInd(readStatus byte) *MReadRecInd {
+	return &MReadRecInd{
+		Type:       TYPE_READ_REC_IND,
+		UUID:       mRetrieveConf.UUID,
+		Version:    MMS_MESSAGE_VERSION_1_1,
+		MessageId:  mRetrieveConf.MessageId,
+		To:         []string{mRetrieveConf.From},
+		Date:       getDate(),
+		ReadStatus: readStatus,
+	}
+}
+
+func NewMReadOrigInd() *MReadOrigInd {
+	return &MReadOrigInd{Type: TYPE_READ_ORIG_IND, UUID: genUUID()}
+}
+
 func NewMRetrieveConf(uuid string) *MRetrieveConf {
 	return &MRetrieveConf{Type: TYPE_RETRIEVE_CONF, UUID: uuid}
 }
 
+func NewMDeliveryInd() *MDeliveryInd {
+	return &MDeliveryInd{Type: TYPE_DELIVERY_IND, UUID: genUUID()}
+}
+
+//NewMForwardReq creates a request for the message center to forward the
+//message it holds at contentLocation to recipients
+func NewMForwardReq(recipients []Address, contentLocation string, deliveryReport, readReport bool) *MForwardReq {
+	uuid := genUUID()
+	return &MForwardReq{
+		Type:            TYPE_FORWARD_REQ,
+		UUID:            uuid,
+		TransactionId:   uuid,
+		Version:         MMS_MESSAGE_VERSION_1_2,
+		Date:            getDate(),
+		To:              formatAddresses(recipients),
+		DeliveryReport:  getDeliveryReport(deliveryReport),
+		ReadReport:      getReadReport(readReport),
+		ContentLocation: contentLocation,
+	}
+}
+
+func NewMForwardConf() *MForwardConf {
+	return &MForwardConf{Type: TYPE_FORWARD_CONF}
+}
+
+//NewForwardMSendReq creates a m-send.req with the content of mRetrieveConf
+//for recipients, it is used to forward a message the message center cannot
+//forward by itself. Text parts are sent as UTF-8.
+func (mRetrieveConf *MRetrieveConf) NewForwardMSendReq(recipients []Address, deliveryReport, readReport bool) (*MSendReq, error) {
+	parts := FlattenAttachments(mRetrieveConf.Attachments)
+	attachments := make([]*Attachment, 0, len(parts))
+	for i := range parts {
+		part := parts[i]
+		if part.IsText() && part.Charset != "" {
+			if err := part.convertToUTF8(); err != nil {
+				return nil, err
+			}
+		}
+		attachments = append(attachments, &part)
+	}
+	mSendReq := NewMSendReq(recipients, attachments, deliveryReport, readReport)
+	if len(mSendReq.Attachments) > 0 && mSendReq.ContentTypeType == "application/smil" && mSendReq.Attachments[0].ContentId != "" {
+		mSendReq.ContentTypeStart = mSendReq.Attachments[0].ContentId
+	}
+	mSendReq.Subject = mRetrieveConf.Subject
+	return mSendReq, nil
+}
+
+func NewMCancelReq() *MCancelReq {
+	return &MCancelReq{Type: TYPE_CANCEL_REQ}
+}
+
+//Cancels tells if mCancelReq refers to the message notified by
+//mNotificationInd, message centers identify it in the Cancel-ID by its
+//content location or by the transaction of the notification.
+func (mCancelReq *MCancelReq) Cancels(mNotificationInd *MNotificationInd) bool {
+	if mCancelReq.CancelId == "" {
+		return false
+	}
+	return mCancelReq.CancelId == mNotificationInd.ContentLocation || mCancelReq.CancelId == mNotificationInd.TransactionId
+}
+
+//NewMCancelConf creates the response to mCancelReq with the given
+//X-Mms-Cancel-Status
+func (mCancelReq *MCancelReq) NewMCancelConf(status byte) *MCancelConf {
+	return &MCancelConf{
+		Type:          TYPE_CANCEL_CONF,
+		UUID:          genUUID(),
+		TransactionId: mCancelReq.TransactionId,
+		Version:       MMS_MESSAGE_VERSION_1_3,
+		CancelStatus:  status,
+	}
+}
+
+//NewMMboxStoreReq creates a request to store the message the message center
+//holds at contentLocation in the MMBox, state and flags are left as the
+//message center sets them when zero and nil.
+func NewMMboxStoreReq(contentLocation string, state byte, flags []MMFlag) *MMboxStoreReq {
+	uuid := genUUID()
+	return &MMboxStoreReq{
+		Type:            TYPE_MBOX_STORE_REQ,
+		UUID:            uuid,
+		TransactionId:   uuid,
+		Version:         MMS_MESSAGE_VERSION_1_2,
+		ContentLocation: contentLocation,
+		MMState:         state,
+		MMFlags:         flags,
+	}
+}
+
+func NewMMboxStoreConf() *MMboxStoreConf {
+	return &MMboxStoreConf{Type: TYPE_MBOX_STORE_CONF}
+}
+
+//NewMMboxViewReq creates a request to list the messages in the MMBox, the
+//selection and the attributes to return are set on the returned request.
+func NewMMboxViewReq() *MMboxViewReq {
+	uuid := genUUID()
+	return &MMboxViewReq{
+		Type:          TYPE_MBOX_VIEW_REQ,
+		UUID:          uuid,
+		TransactionId: uuid,
+		Version:       MMS_MESSAGE_VERSION_1_2,
+	}
+}
+
+func NewMMboxViewConf() *MMboxViewConf {
+	return &MMboxViewConf{Type: TYPE_MBOX_VIEW_CONF}
+}
+
+//NewMMboxUploadReq creates a request to upload mSendReq to the MMBox instead
+//of sending it, the message is encoded as a m-mbox-descr.
+func (mSendReq *MSendReq) NewMMboxUploadReq(state byte, flags []MMFlag) (*MMboxUploadReq, error) {
+	descr := *mSendReq
+	descr.Type = TYPE_MBOX_DESCR
+	descr.TransactionId = ""
+	descr.SenderVisibility = 0
+	descr.Store = 0
+	descr.MMState = 0
+	descr.MMFlags = nil
+	var b bytes.Buffer
+	if err := NewEncoder(&b).Encode(&descr); err != nil {
+		return nil, err
+	}
+	uuid := genUUID()
+	return &MMboxUploadReq{
+		Type:          TYPE_MBOX_UPLOAD_REQ,
+		UUID:          uuid,
+		TransactionId: uuid,
+		Version:       MMS_MESSAGE_VERSION_1_2,
+		MMState:       state,
+		MMFlags:       flags,
+		ContentType:   VND_WAP_MMS_MESSAGE,
+		Message:       b.Bytes(),
+	}, nil
+}
+
+func NewMMboxUploadConf() *MMboxUploadConf {
+	return &MMboxUploadConf{Type: TYPE_MBOX_UPLOAD_CONF}
+}
+
+//NewMMboxDeleteReq creates a request to delete the messages at
+//contentLocations from the MMBox
+func NewMMboxDeleteReq(contentLocations []string) *MMboxDeleteReq {
+	uuid := genUUID()
+	return &MMboxDeleteReq{
+		Type:            TYPE_MBOX_DELETE_REQ,
+		UUID:            uuid,
+		TransactionId:   uuid,
+		Version:         MMS_MESSAGE_VERSION_1_2,
+		ContentLocation: contentLocations,
+	}
+}
+
+func NewMMboxDeleteConf() *MMboxDeleteConf {
+	return &MMboxDeleteConf{Type: TYPE_MBOX_DELETE_CONF}
+}
+
+//Descriptions decodes the m-mbox-descr messages held in the attachments of
+//mMboxViewConf with DefaultDecoderLimits, attachments of other types are
+//skipped.
+func (mMboxViewConf *MMboxViewConf) Descriptions() ([]*MMboxDescr, error) {
+	var descrs []*MMboxDescr
+	for _, part := range mMboxViewConf.Attachments {
+		if part.MediaType != VND_WAP_MMS_MESSAGE {
+			continue
+		}
+		descr := &MMboxDescr{Type: TYPE_MBOX_DESCR}
+		if err := NewDecoder(part.Data, DefaultDecoderLimits).Decode(descr); err != nil {
+			return nil, err
+		}
+		descrs = append(descrs, descr)
+	}
+	return descrs, nil
+}
+
 func genUUID() string {
 	var id string
 	random, err := os.Open("/dev/urandom")
@@ -354,7 +1035,40 @@ var ErrTransient = errors.New("Error-transient-failure")
 var ErrPermanent = errors.New("Error-permament-failure")
 
 func (mSendConf *MSendConf) Status() error {
-	s := mSendConf.ResponseStatus
+	return responseStatusError(mSendConf.ResponseStatus)
+}
+
+func (mForwardConf *MForwardConf) Status() error {
+	return responseStatusError(mForwardConf.ResponseStatus)
+}
+
+func (mMboxViewConf *MMboxViewConf) Status() error {
+	return responseStatusError(mMboxViewConf.ResponseStatus)
+}
+
+func (mMboxStoreConf *MMboxStoreConf) Status() error {
+	return storeStatusError(mMboxStoreConf.StoreStatus)
+}
+
+func (mMboxUploadConf *MMboxUploadConf) Status() error {
+	return storeStatusError(mMboxUploadConf.StoreStatus)
+}
+
+//storeStatusError maps an X-Mms-Store-Status value to nil, ErrTransient or
+//ErrPermanent, reserved values are handled as their range's generic failure.
+func storeStatusError(s byte) error {
+	switch {
+	case s == StoreStatusSuccess:
+		return nil
+	case s >= StoreStatusErrorTransientFailure && s <= ResponseStatusErrorTransientMaxReserved:
+		return ErrTransient
+	}
+	return ErrPermanent
+}
+
+//responseStatusError maps an X-Mms-Response-Status value to nil, ErrTransient
+//or ErrPermanent
+func responseStatusError(s byte) error {
 	// these are case by case Response Status and we need to determine each one
 	switch s {
 	case ResponseStatusOk:
@@ -424,6 +1138,9 @@ func getDate() (date uint64) {
 	return date
 }
 
+//processAttachments puts the SMIL part first, generating one when none is
+//provided, and returns the values for the Start and Type content type
+//parameters.
 func processAttachments(a []*Attachment) (oa []*Attachment, smilStart, smilType string) {
 	oa = make([]*Attachment, 0, len(a))
 	for i := range a {
@@ -439,5 +1156,21 @@ func processAttachments(a []*Attachment) (oa []*Attachment, smilStart, smilType
 			oa = append(oa, a[i])
 		}
 	}
+	if smilType == "" && len(oa) > 0 {
+		// the generated SMIL needs a way to reference every part
+		for i := range oa {
+			if oa[i].ContentId == "" && oa[i].ContentLocation == "" {
+				oa[i].ContentId = fmt.Sprintf("<part%d>", i)
+			}
+		}
+		smil, err := NewSmilAttachment(oa)
+		if err != nil {
+			log.Println("Cannot generate SMIL:", err)
+			return oa, smilStart, smilType
+		}
+		oa = append([]*Attachment{smil}, oa...)
+		smilStart = smil.ContentId
+		smilType = smil.MediaType
+	}
 	return oa, smilStart, smilType
 }
@@ -21,17 +21,142 @@
 
 package mms
 
-import . "launchpad.net/gocheck"
+import (
+	"bytes"
+
+	. "launchpad.net/gocheck"
+)
 
 type MMSTestSuite struct{}
 
 var _ = Suite(&MMSTestSuite{})
 
 func (s *MMSTestSuite) TestNewMSendReq(c *C) {
-	recipients := []string{"+11111", "+22222", "+33333"}
+	recipients := []Address{{Type: AddressPLMN, Value: "+11111"}, {Type: AddressPLMN, Value: "+22222"}, {Type: AddressPLMN, Value: "+33333"}}
 	expectedRecipients := []string{"+11111/TYPE=PLMN", "+22222/TYPE=PLMN", "+33333/TYPE=PLMN"}
-	mSendReq := NewMSendReq(recipients, []*Attachment{}, false)
+	mSendReq := NewMSendReq(recipients, []*Attachment{}, false, false)
 	c.Check(mSendReq.To, DeepEquals, expectedRecipients)
 	c.Check(mSendReq.ContentType, Equals, "application/vnd.wap.multipart.related")
 	c.Check(mSendReq.Type, Equals, byte(TYPE_SEND_REQ))
 }
+
+func (s *MMSTestSuite) TestNewMSendReqWithReadReport(c *C) {
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, []*Attachment{}, false, true)
+	c.Check(mSendReq.ReadReport, Equals, ReadReportYes)
+	c.Check(mSendReq.DeliveryReport, Equals, DeliveryReportNo)
+}
+
+func (s *MMSTestSuite) TestGetTextDataParts(c *C) {
+	latin1 := []byte{'C', 'a', 'f', 0xE9}
+	mRetrieveConf := &MRetrieveConf{
+		Attachments: []Attachment{
+			{MediaType: "application/smil", Data: []byte("<smil>")},
+			{MediaType: "text/plain;charset=iso-8859-1", Charset: "iso-8859-1", Data: latin1},
+			{MediaType: "text/plain;charset=iso-10646-ucs-2", Charset: "iso-10646-ucs-2", Data: []byte{0x00, 'h', 0x00, 'i'}},
+			{MediaType: "text/plain;charset=x-unknown", Charset: "x-unknown", Data: []byte("raw")},
+			{MediaType: "image/jpeg", Data: []byte{0xFF, 0xD8}},
+		},
+	}
+	dataParts := mRetrieveConf.GetTextDataParts()
+	c.Assert(dataParts, HasLen, 4)
+	c.Check(string(dataParts[0].Data), Equals, "Café")
+	c.Check(dataParts[0].MediaType, Equals, "text/plain;charset=utf-8")
+	c.Check(dataParts[0].Charset, Equals, "utf-8")
+	c.Check(string(dataParts[1].Data), Equals, "hi")
+	// a part that cannot be converted keeps its bytes and charset
+	c.Check(string(dataParts[2].Data), Equals, "raw")
+	c.Check(dataParts[2].MediaType, Equals, "text/plain;charset=x-unknown")
+	c.Check(dataParts[2].Charset, Equals, "x-unknown")
+	c.Check(dataParts[3].Data, DeepEquals, []byte{0xFF, 0xD8})
+
+	// the original parts are untouched
+	original := mRetrieveConf.GetDataParts()
+	c.Check(original[0].Data, DeepEquals, latin1)
+	c.Check(original[0].Charset, Equals, "iso-8859-1")
+}
+
+func (s *MMSTestSuite) TestMSendReqSetCcBcc(c *C) {
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, []*Attachment{}, false, false)
+	mSendReq.SetCc([]Address{{Type: AddressPLMN, Value: "+22222"}, {Type: AddressPLMN, Value: "+33333"}})
+	mSendReq.SetBcc([]Address{{Type: AddressPLMN, Value: "+44444"}})
+	c.Check(mSendReq.Cc, DeepEquals, []string{"+22222/TYPE=PLMN", "+33333/TYPE=PLMN"})
+	c.Check(mSendReq.Bcc, DeepEquals, []string{"+44444/TYPE=PLMN"})
+}
+
+func (s *MMSTestSuite) TestNewForwardMSendReq(c *C) {
+	inputBytes := []byte{
+		//Message Type m-retrieve.conf
+		0x8C, 0x84,
+		// MMS Version 1.2
+		0x8D, 0x92,
+		// Subject "Holidays"
+		0x96, 'H', 'o', 'l', 'i', 'd', 'a', 'y', 's', 0x00,
+		// Content Type application/vnd.wap.multipart.mixed
+		0x84, 0xa3,
+		// 2 parts
+		0x02,
+		// headers length 13, data length 4
+		0x0d, 0x04,
+		// Content Type length 3, text/plain with charset iso-8859-1
+		0x03, 0x83, 0x81, 0x84,
+		// Content-ID "<text0>"
+		0xc0, '<', 't', 'e', 'x', 't', '0', '>', 0x00,
+		// "Café" in iso-8859-1
+		'C', 'a', 'f', 0xE9,
+		// headers length 11, data length 2
+		0x0b, 0x02,
+		// Content Type image/jpeg
+		0x9e,
+		// Content-ID "<image0>"
+		0xc0, '<', 'i', 'm', 'a', 'g', 'e', '0', '>', 0x00,
+		0xFF, 0xD8,
+	}
+	mRetrieveConf := NewMRetrieveConf("1")
+	c.Assert(NewDecoder(inputBytes).Decode(mRetrieveConf), IsNil)
+	c.Assert(mRetrieveConf.Attachments, HasLen, 2)
+	c.Assert(mRetrieveConf.Attachments[0].Charset, Equals, "iso-8859-1")
+
+	to := []Address{{Type: AddressEmail, Value: "user@example.com"}}
+	mSendReq, err := mRetrieveConf.NewForwardMSendReq(to, false, false)
+	c.Assert(err, IsNil)
+	c.Check(mSendReq.To, DeepEquals, []string{"user@example.com"})
+	c.Check(mSendReq.Subject, Equals, "Holidays")
+	c.Check(mSendReq.ContentTypeType, Equals, "application/smil")
+	c.Assert(mSendReq.Attachments, HasLen, 3)
+	c.Check(mSendReq.Attachments[0].MediaType, Equals, "application/smil")
+	c.Check(string(mSendReq.Attachments[1].Data), Equals, "Café")
+	c.Check(mSendReq.Attachments[1].MediaType, Equals, "text/plain;charset=utf-8")
+	c.Check(mSendReq.Attachments[1].Charset, Equals, "utf-8")
+	c.Check(mSendReq.Attachments[2].MediaType, Equals, "image/jpeg")
+
+	// the charset survives encoding the forwarded message
+	var b bytes.Buffer
+	c.Assert(NewEncoder(&b).Encode(mSendReq), IsNil)
+	encoded := b.Bytes()
+	encoded[1] = TYPE_RETRIEVE_CONF
+	forwarded := NewMRetrieveConf("2")
+	c.Assert(NewDecoder(encoded).Decode(forwarded), IsNil)
+	c.Assert(forwarded.Attachments, HasLen, 3)
+	c.Check(string(forwarded.Attachments[1].Data), Equals, "Café")
+	c.Check(forwarded.Attachments[1].MediaType, Equals, "text/plain;charset=utf-8")
+
+	// the received message is untouched
+	c.Check(mRetrieveConf.Attachments[0].Charset, Equals, "iso-8859-1")
+	c.Check(mRetrieveConf.Attachments[0].Data, DeepEquals, []byte{'C', 'a', 'f', 0xE9})
+}
+
+func (s *MMSTestSuite) TestNewForwardMSendReqSmilStart(c *C) {
+	mRetrieveConf := &MRetrieveConf{
+		Attachments: []Attachment{
+			{MediaType: "application/smil", ContentId: "<smil>", Data: []byte("<smil><body/></smil>")},
+			{MediaType: "application/vnd.wap.multipart.mixed", Parts: []Attachment{
+				{MediaType: "image/jpeg", ContentId: "<image0>", Data: []byte{0xFF, 0xD8}},
+			}},
+		},
+	}
+	mSendReq, err := mRetrieveConf.NewForwardMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, false, false)
+	c.Assert(err, IsNil)
+	c.Check(mSendReq.ContentTypeStart, Equals, "<smil>")
+	c.Assert(mSendReq.Attachments, HasLen, 2)
+	c.Check(mSendReq.Attachments[0].ContentId, Equals, "<smil>")
+}
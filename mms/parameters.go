@@ -66,6 +66,7 @@ const (
 	SHORT_LENGTH_MAX = 30
 	LENGTH_QUOTE     = 31
 	STRING_QUOTE     = 34
+	TEXT_QUOTE       = 127
 	SHORT_FILTER     = 0x80
 )
 
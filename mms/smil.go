@@ -0,0 +1,336 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mms
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	smilContentId   = "<smil>"
+	smilName        = "smil.xml"
+	smilSlideDur    = "5000ms"
+	smilImageRegion = "Image"
+	smilTextRegion  = "Text"
+	smilMediaType   = "application/smil"
+)
+
+type slideParts struct {
+	media, text *Attachment
+}
+
+// NewSmilAttachment builds an application/smil attachment presenting
+// attachments as a slideshow, every image, video or audio part starts a new
+// slide and a text part is shown along with the media before it.
+func NewSmilAttachment(attachments []*Attachment) (*Attachment, error) {
+	smil, err := BuildSmil(attachments)
+	if err != nil {
+		return nil, err
+	}
+	return &Attachment{
+		MediaType:       smilMediaType,
+		ContentId:       smilContentId,
+		ContentLocation: smilName,
+		Name:            smilName,
+		Data:            smil,
+	}, nil
+}
+
+// BuildSmil creates the SMIL document for attachments with an Image and a
+// Text region, parts are referenced through their Content-ID with cid: URLs
+// or else through their Content-Location.
+func BuildSmil(attachments []*Attachment) ([]byte, error) {
+	if len(attachments) == 0 {
+		return nil, errors.New("cannot build SMIL without attachments")
+	}
+	var slides []slideParts
+	for _, a := range attachments {
+		last := len(slides) - 1
+		if a.IsText() {
+			if last >= 0 && slides[last].text == nil {
+				slides[last].text = a
+			} else {
+				slides = append(slides, slideParts{text: a})
+			}
+		} else {
+			slides = append(slides, slideParts{media: a})
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<smil>")
+	b.WriteString("<head><layout>")
+	b.WriteString(`<root-layout width="100%" height="100%"/>`)
+	fmt.Fprintf(&b, `<region id="%s" width="100%%" height="80%%" left="0%%" top="0%%" fit="meet"/>`, smilImageRegion)
+	fmt.Fprintf(&b, `<region id="%s" width="100%%" height="20%%" left="0%%" top="80%%" fit="scroll"/>`, smilTextRegion)
+	b.WriteString("</layout></head>")
+	b.WriteString("<body>")
+	for _, slide := range slides {
+		fmt.Fprintf(&b, `<par dur="%s">`, smilSlideDur)
+		if slide.media != nil {
+			writeSmilMedia(&b, slide.media)
+		}
+		if slide.text != nil {
+			fmt.Fprintf(&b, `<text src="%s" region="%s"/>`, smilSrc(slide.text), smilTextRegion)
+		}
+		b.WriteString("</par>")
+	}
+	b.WriteString("</body>")
+	b.WriteString("</smil>")
+	return b.Bytes(), nil
+}
+
+func writeSmilMedia(b *bytes.Buffer, a *Attachment) {
+	switch {
+	case strings.HasPrefix(a.MediaType, "image/"):
+		fmt.Fprintf(b, `<img src="%s" region="%s"/>`, smilSrc(a), smilImageRegion)
+	case strings.HasPrefix(a.MediaType, "video/"):
+		fmt.Fprintf(b, `<video src="%s" region="%s"/>`, smilSrc(a), smilImageRegion)
+	case strings.HasPrefix(a.MediaType, "audio/"):
+		fmt.Fprintf(b, `<audio src="%s"/>`, smilSrc(a))
+	default:
+		fmt.Fprintf(b, `<ref src="%s"/>`, smilSrc(a))
+	}
+}
+
+// smilSrc returns the cid: URL for a, the angle brackets enclosing a
+// Content-ID are not part of the URL. Parts without a Content-ID are
+// referenced by their Content-Location.
+func smilSrc(a *Attachment) string {
+	src := a.ContentLocation
+	if a.ContentId != "" || src == "" {
+		src = "cid:" + strings.TrimSuffix(strings.TrimPrefix(a.ContentId, "<"), ">")
+	}
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(src))
+	return b.String()
+}
+
+// Smil holds a parsed SMIL presentation with its slides in playing order
+type Smil struct {
+	Width, Height string
+	Regions       []SmilRegion
+	Slides        []SmilSlide
+}
+
+// SmilRegion holds the layout of a region where media is rendered
+type SmilRegion struct {
+	Id                       string
+	Left, Top, Width, Height string
+	Fit                      string
+}
+
+// SmilSlide holds the media presented together in a <par> for Duration
+type SmilSlide struct {
+	Duration time.Duration
+	Media    []SmilMedia
+}
+
+// SmilMedia holds a media element of a slide, Kind is the element name such
+// as img, text, audio, video or ref and Attachment is the part Src refers to
+// or nil if it cannot be found in the message.
+type SmilMedia struct {
+	Kind       string
+	Src        string
+	Region     string
+	Begin      time.Duration
+	Duration   time.Duration
+	Attachment *Attachment
+}
+
+type smilDocument struct {
+	Layout struct {
+		RootLayout struct {
+			Width  string `xml:"width,attr"`
+			Height string `xml:"height,attr"`
+		} `xml:"root-layout"`
+		Regions []struct {
+			Id     string `xml:"id,attr"`
+			Left   string `xml:"left,attr"`
+			Top    string `xml:"top,attr"`
+			Width  string `xml:"width,attr"`
+			Height string `xml:"height,attr"`
+			Fit    string `xml:"fit,attr"`
+		} `xml:"region"`
+	} `xml:"head>layout"`
+	Body struct {
+		Elements []smilElement `xml:",any"`
+	} `xml:"body"`
+}
+
+type smilElement struct {
+	XMLName  xml.Name
+	Src      string        `xml:"src,attr"`
+	Region   string        `xml:"region,attr"`
+	Begin    string        `xml:"begin,attr"`
+	Dur      string        `xml:"dur,attr"`
+	Children []smilElement `xml:",any"`
+}
+
+// GetSmilSlides parses the SMIL part of the message and resolves its media
+// references against the message attachments.
+func (pdu *MRetrieveConf) GetSmilSlides() (*Smil, error) {
+	smil, err := pdu.GetSmil()
+	if err != nil {
+		return nil, err
+	}
+	return ParseSmil([]byte(smil), FlattenAttachments(pdu.Attachments))
+}
+
+// ParseSmil parses data as a SMIL presentation, media src attributes are
+// resolved against attachments by Content-ID for cid: URLs and by
+// Content-Location or Name otherwise.
+func ParseSmil(data []byte, attachments []Attachment) (*Smil, error) {
+	var doc smilDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("cannot parse SMIL: %s", err)
+	}
+	smil := &Smil{
+		Width:  doc.Layout.RootLayout.Width,
+		Height: doc.Layout.RootLayout.Height,
+	}
+	for _, r := range doc.Layout.Regions {
+		smil.Regions = append(smil.Regions, SmilRegion{
+			Id:     r.Id,
+			Left:   r.Left,
+			Top:    r.Top,
+			Width:  r.Width,
+			Height: r.Height,
+			Fit:    r.Fit,
+		})
+	}
+	for _, e := range flattenSeq(doc.Body.Elements) {
+		var slide SmilSlide
+		if e.XMLName.Local == "par" {
+			for _, m := range e.Children {
+				slide.Media = append(slide.Media, newSmilMedia(m, attachments))
+			}
+		} else {
+			// media outside of a <par> is a slide on its own
+			slide.Media = append(slide.Media, newSmilMedia(e, attachments))
+		}
+		slide.Duration = parseSmilTime(e.Dur)
+		if slide.Duration == 0 {
+			for _, m := range slide.Media {
+				if end := m.Begin + m.Duration; end > slide.Duration {
+					slide.Duration = end
+				}
+			}
+		}
+		smil.Slides = append(smil.Slides, slide)
+	}
+	return smil, nil
+}
+
+// flattenSeq replaces <seq> elements with their children as the body
+// already plays its elements in sequence.
+func flattenSeq(elements []smilElement) []smilElement {
+	var flat []smilElement
+	for _, e := range elements {
+		if e.XMLName.Local == "seq" {
+			flat = append(flat, flattenSeq(e.Children)...)
+		} else {
+			flat = append(flat, e)
+		}
+	}
+	return flat
+}
+
+func newSmilMedia(e smilElement, attachments []Attachment) SmilMedia {
+	return SmilMedia{
+		Kind:       e.XMLName.Local,
+		Src:        e.Src,
+		Region:     e.Region,
+		Begin:      parseSmilTime(e.Begin),
+		Duration:   parseSmilTime(e.Dur),
+		Attachment: findSmilAttachment(e.Src, attachments),
+	}
+}
+
+func findSmilAttachment(src string, attachments []Attachment) *Attachment {
+	if src == "" {
+		return nil
+	}
+	if strings.HasPrefix(src, "cid:") {
+		cid := strings.TrimPrefix(src, "cid:")
+		for i := range attachments {
+			if strings.Trim(attachments[i].ContentId, "<>") == cid {
+				return &attachments[i]
+			}
+		}
+		return nil
+	}
+	for i := range attachments {
+		if attachments[i].ContentLocation == src || attachments[i].Name == src {
+			return &attachments[i]
+		}
+	}
+	return nil
+}
+
+// parseSmilTime parses SMIL clock values such as "5000ms", "5s", "5",
+// "0.5min" or "00:00:05", unsupported values such as "indefinite" are 0.
+func parseSmilTime(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if strings.Contains(v, ":") {
+		var d time.Duration
+		for _, part := range strings.Split(v, ":") {
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0
+			}
+			d = d*60 + time.Duration(f*float64(time.Second))
+		}
+		return d
+	}
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"ms", time.Millisecond},
+		{"min", time.Minute},
+		{"h", time.Hour},
+		{"s", time.Second},
+	}
+	unit := time.Second
+	for _, u := range units {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSuffix(v, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return time.Duration(f * float64(unit))
+}
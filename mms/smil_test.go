@@ -0,0 +1,176 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package mms
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	. "launchpad.net/gocheck"
+)
+
+type SmilTestSuite struct{}
+
+var _ = Suite(&SmilTestSuite{})
+
+func (s *SmilTestSuite) TestBuildSmil(c *C) {
+	attachments := []*Attachment{
+		{MediaType: "image/jpeg", ContentId: "<image0>"},
+		{MediaType: "text/plain", ContentId: "text0"},
+		{MediaType: "image/png", ContentId: "image1"},
+		{MediaType: "audio/amr", ContentId: "audio0"},
+		{MediaType: "text/plain", ContentId: "text1"},
+		{MediaType: "text/plain", ContentId: "text2"},
+	}
+	smil, err := BuildSmil(attachments)
+	c.Assert(err, IsNil)
+	c.Assert(xml.Unmarshal(smil, new(interface{})), IsNil)
+
+	smilStr := string(smil)
+	c.Check(strings.HasPrefix(smilStr, "<smil>"), Equals, true)
+	c.Check(strings.Count(smilStr, "<par "), Equals, 4)
+	c.Check(smilStr, Matches, `.*<par dur="5000ms"><img src="cid:image0" region="Image"/><text src="cid:text0" region="Text"/></par>.*`)
+	c.Check(smilStr, Matches, `.*<par dur="5000ms"><img src="cid:image1" region="Image"/></par>.*`)
+	c.Check(smilStr, Matches, `.*<par dur="5000ms"><audio src="cid:audio0"/><text src="cid:text1" region="Text"/></par>.*`)
+	c.Check(smilStr, Matches, `.*<par dur="5000ms"><text src="cid:text2" region="Text"/></par>.*`)
+}
+
+func (s *SmilTestSuite) TestBuildSmilContentLocation(c *C) {
+	attachments := []*Attachment{
+		{MediaType: "image/jpeg", ContentLocation: "image0.jpg"},
+		{MediaType: "text/plain", ContentLocation: "text0.txt"},
+	}
+	smil, err := BuildSmil(attachments)
+	c.Assert(err, IsNil)
+	c.Check(string(smil), Matches, `.*<par dur="5000ms"><img src="image0.jpg" region="Image"/><text src="text0.txt" region="Text"/></par>.*`)
+}
+
+func (s *SmilTestSuite) TestBuildSmilWithoutAttachments(c *C) {
+	_, err := BuildSmil(nil)
+	c.Check(err, NotNil)
+}
+
+func (s *SmilTestSuite) TestNewMSendReqGeneratesSmil(c *C) {
+	attachments := []*Attachment{
+		{MediaType: "image/jpeg", ContentId: "image0"},
+		{MediaType: "text/plain", ContentId: "text0"},
+	}
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, attachments, false, false)
+	c.Assert(mSendReq.Attachments, HasLen, 3)
+	c.Check(mSendReq.Attachments[0].MediaType, Equals, "application/smil")
+	c.Check(mSendReq.ContentTypeStart, Equals, "<smil>")
+	c.Check(mSendReq.ContentTypeType, Equals, "application/smil")
+}
+
+func (s *SmilTestSuite) TestNewMSendReqGeneratesSmilContentIds(c *C) {
+	attachments := []*Attachment{
+		{MediaType: "image/jpeg"},
+		{MediaType: "text/plain", ContentLocation: "text0.txt"},
+	}
+	mSendReq := NewMSendReq([]Address{{Type: AddressPLMN, Value: "+11111"}}, attachments, false, false)
+	c.Assert(mSendReq.Attachments, HasLen, 3)
+	c.Check(mSendReq.Attachments[1].ContentId, Equals, "<part0>")
+	c.Check(mSendReq.Attachments[2].ContentId, Equals, "")
+	smilStr := string(mSendReq.Attachments[0].Data)
+	c.Check(strings.Contains(smilStr, `src="cid:"`), Equals, false)
+	c.Check(smilStr, Matches, `.*<img src="cid:part0" region="Image"/><text src="text0.txt" region="Text"/>.*`)
+}
+
+func (s *SmilTestSuite) TestParseSmil(c *C) {
+	smil := `<smil>
+<head>
+<layout>
+<root-layout width="320" height="480"/>
+<region id="Image" left="0" top="0" width="100%" height="80%" fit="meet"/>
+<region id="Text" left="0" top="80%" width="100%" height="20%"/>
+</layout>
+</head>
+<body>
+<par dur="5000ms">
+<img src="cid:image0" region="Image"/>
+<text src="text0.txt" region="Text"/>
+</par>
+<par>
+<audio src="cid:missing" begin="1s" dur="00:00:03"/>
+</par>
+</body>
+</smil>`
+	attachments := []Attachment{
+		{MediaType: "image/jpeg", ContentId: "<image0>"},
+		{MediaType: "text/plain", ContentId: "<text0>", ContentLocation: "text0.txt"},
+	}
+	presentation, err := ParseSmil([]byte(smil), attachments)
+	c.Assert(err, IsNil)
+	c.Check(presentation.Width, Equals, "320")
+	c.Check(presentation.Height, Equals, "480")
+	c.Assert(presentation.Regions, HasLen, 2)
+	c.Check(presentation.Regions[0], DeepEquals, SmilRegion{Id: "Image", Left: "0", Top: "0", Width: "100%", Height: "80%", Fit: "meet"})
+	c.Assert(presentation.Slides, HasLen, 2)
+
+	slide := presentation.Slides[0]
+	c.Check(slide.Duration, Equals, 5*time.Second)
+	c.Assert(slide.Media, HasLen, 2)
+	c.Check(slide.Media[0].Kind, Equals, "img")
+	c.Check(slide.Media[0].Region, Equals, "Image")
+	c.Check(slide.Media[0].Attachment, Equals, &attachments[0])
+	c.Check(slide.Media[1].Kind, Equals, "text")
+	c.Check(slide.Media[1].Attachment, Equals, &attachments[1])
+
+	slide = presentation.Slides[1]
+	c.Check(slide.Duration, Equals, 4*time.Second)
+	c.Assert(slide.Media, HasLen, 1)
+	c.Check(slide.Media[0].Kind, Equals, "audio")
+	c.Check(slide.Media[0].Attachment, IsNil)
+}
+
+func (s *SmilTestSuite) TestParseGeneratedSmil(c *C) {
+	attachments := []Attachment{
+		{MediaType: "image/jpeg", ContentId: "image0"},
+		{MediaType: "text/plain", ContentId: "text0"},
+	}
+	smil, err := BuildSmil([]*Attachment{&attachments[0], &attachments[1]})
+	c.Assert(err, IsNil)
+	presentation, err := ParseSmil(smil, attachments)
+	c.Assert(err, IsNil)
+	c.Assert(presentation.Slides, HasLen, 1)
+	c.Check(presentation.Slides[0].Duration, Equals, 5*time.Second)
+	c.Check(presentation.Slides[0].Media[0].Attachment, Equals, &attachments[0])
+	c.Check(presentation.Slides[0].Media[1].Attachment, Equals, &attachments[1])
+}
+
+func (s *SmilTestSuite) TestParseSmilTime(c *C) {
+	times := map[string]time.Duration{
+		"":           0,
+		"5000ms":     5 * time.Second,
+		"5s":         5 * time.Second,
+		"5":          5 * time.Second,
+		"0.5min":     30 * time.Second,
+		"1h":         time.Hour,
+		"01:30":      90 * time.Second,
+		"00:00:05":   5 * time.Second,
+		"indefinite": 0,
+	}
+	for v, d := range times {
+		c.Check(parseSmilTime(v), Equals, d, Commentf("%s", v))
+	}
+}
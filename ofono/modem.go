@@ -479,6 +479,17 @@ func (modem *Modem) GetMMSContexts(preferredContext dbus.ObjectPath) (mmsContext
 	return mmsContexts, nil
 }
 
+//MobileCountryCode returns the mobile country code of the SIM or an empty
+//string if it cannot be retrieved
+func (modem *Modem) MobileCountryCode() string {
+	v, err := modem.getProperty(SIM_MANAGER_INTERFACE, "MobileCountryCode")
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
+	return reflect.ValueOf(v.Value).String()
+}
+
 func (modem *Modem) getProperty(interfaceName, propertyName string) (*dbus.Variant, error) {
 	errorString := "Cannot retrieve %s from %s for %s: %s"
 	rilObj := modem.conn.Object(OFONO_SENDER, modem.Modem)
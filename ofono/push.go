@@ -43,9 +43,9 @@ type PushPDUDecoder struct {
 	mms.MMSDecoder
 }
 
-func NewDecoder(data []byte) *PushPDUDecoder {
+func NewDecoder(data []byte, limits ...mms.DecoderLimits) *PushPDUDecoder {
 	decoder := new(PushPDUDecoder)
-	decoder.MMSDecoder.Data = data
+	decoder.MMSDecoder = *mms.NewDecoder(data, limits...)
 	return decoder
 }
 
@@ -57,6 +57,9 @@ func NewDecoder(data []byte) *PushPDUDecoder {
 // provided to and reported from the underlying transport. The Data field starts immediately after the Headers field and
 // ends at the end of the SDU.
 func (dec *PushPDUDecoder) Decode(pdu *PushPDU) (err error) {
+	if len(dec.Data) < 2 {
+		return &mms.TruncatedError{Offset: len(dec.Data) - 1, Needed: 2 - len(dec.Data), Length: len(dec.Data)}
+	}
 	if PDU(dec.Data[1]) != PUSH {
 		return errors.New(fmt.Sprintf("%x != %x is not a push PDU", PDU(dec.Data[1]), PUSH))
 	}
@@ -66,14 +69,20 @@ func (dec *PushPDUDecoder) Decode(pdu *PushPDU) (err error) {
 	if _, err = dec.ReadUintVar(&rValue, "HeaderLength"); err != nil {
 		return err
 	}
-	if err = dec.ReadMediaType(&rValue, "ContentType"); err != nil {
+	var contentType mms.Attachment
+	ctValue := reflect.ValueOf(&contentType).Elem()
+	if err = dec.ReadAttachment(&ctValue); err != nil {
 		return err
 	}
+	pdu.ContentType = contentType.MediaType
 	dec.Offset++
 	remainHeaders := int(pdu.HeaderLength) - dec.Offset + 3
 	if err = dec.decodeHeaders(pdu, remainHeaders); err != nil {
 		return err
 	}
+	if pdu.HeaderLength+3 > uint64(len(dec.Data)) {
+		return &mms.TruncatedError{Offset: dec.Offset, Needed: int(pdu.HeaderLength) + 3 - dec.Offset, Length: len(dec.Data)}
+	}
 	pdu.Data = dec.Data[(pdu.HeaderLength + 3):]
 	return nil
 }
@@ -81,7 +90,11 @@ func (dec *PushPDUDecoder) Decode(pdu *PushPDU) (err error) {
 func (dec *PushPDUDecoder) decodeHeaders(pdu *PushPDU, hdrLengthRemain int) error {
 	rValue := reflect.ValueOf(pdu).Elem()
 	var err error
-	for ; dec.Offset < (hdrLengthRemain + dec.Offset); dec.Offset++ {
+	end := dec.Offset + hdrLengthRemain
+	if end > len(dec.Data) {
+		end = len(dec.Data)
+	}
+	for ; dec.Offset < end; dec.Offset++ {
 		param := dec.Data[dec.Offset] & 0x7F
 		switch param {
 		case X_WAP_APPLICATION_ID:
@@ -89,9 +102,11 @@ func (dec *PushPDUDecoder) decodeHeaders(pdu *PushPDU, hdrLengthRemain int) erro
 		case PUSH_FLAG:
 			_, err = dec.ReadShortInteger(&rValue, "PushFlag")
 		case ENCODING_VERSION:
-			dec.Offset++
-			pdu.EncodingVersion = dec.Data[dec.Offset] & 0x7F
-			dec.Offset++
+			var v byte
+			if v, err = dec.ReadByte(nil, ""); err == nil {
+				pdu.EncodingVersion = v & 0x7F
+				dec.Offset++
+			}
 		case CONTENT_LENGTH:
 			_, err = dec.ReadInteger(&rValue, "ContentLength")
 		case X_WAP_INITIATOR_URI:
@@ -99,10 +114,10 @@ func (dec *PushPDUDecoder) decodeHeaders(pdu *PushPDU, hdrLengthRemain int) erro
 			v, err = dec.ReadString(nil, "")
 			fmt.Println("Unsaved value decoded:", v)
 		default:
-			err = fmt.Errorf("Unhandled header data %#x @%d", dec.Data[dec.Offset], dec.Offset)
+			err = fmt.Errorf("%w data %#x @%d", mms.ErrUnsupportedHeader, dec.Data[dec.Offset], dec.Offset)
 		}
 		if err != nil {
-			return fmt.Errorf("error while decoding %#x @%d: ", param, dec.Offset, err)
+			return fmt.Errorf("error while decoding %#x @%d: %w", param, dec.Offset, err)
 		} else if pdu.ApplicationId != 0 {
 			return nil
 		}
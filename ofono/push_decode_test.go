@@ -223,3 +223,25 @@ func (s *PushDecodeTestSuite) TestDecodePlayPoland(c *C) {
 	c.Check(s.pdu.ContentType, Equals, mms.VND_WAP_MMS_MESSAGE)
 	c.Check(len(s.pdu.Data), Equals, 102)
 }
+
+func (s *PushDecodeTestSuite) TestDecodeContentTypeWithParameters(c *C) {
+	inputBytes := []byte{
+		0x2e, 0x06, 0x06, 0x03, 0xbe, 0x81, 0xea, 0xaf, 0x84, 0x8c, 0x82, 0x98,
+		0x31, 0x34, 0x34, 0x32, 0x34, 0x30, 0x31, 0x33, 0x31, 0x38, 0x40, 0x6d,
+		0x6d, 0x73, 0x32, 0x00, 0x8d, 0x92, 0x89, 0x18, 0x80, 0x2b, 0x34, 0x38,
+		0x38, 0x38, 0x32, 0x30, 0x34, 0x30, 0x32, 0x32, 0x35, 0x2f, 0x54, 0x59,
+		0x50, 0x45, 0x3d, 0x50, 0x4c, 0x4d, 0x4e, 0x00, 0x8f, 0x81, 0x86, 0x80,
+		0x8a, 0x80, 0x8e, 0x03, 0x03, 0xad, 0x21, 0x88, 0x05, 0x81, 0x03, 0x03,
+		0xf4, 0x80, 0x83, 0x68, 0x74, 0x74, 0x70, 0x3a, 0x2f, 0x2f, 0x6d, 0x6d,
+		0x73, 0x63, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x2e, 0x70, 0x6c, 0x2f, 0x3f,
+		0x69, 0x64, 0x3d, 0x31, 0x34, 0x34, 0x32, 0x34, 0x30, 0x31, 0x33, 0x31,
+		0x38, 0x42, 0x00,
+	}
+	dec := NewDecoder(inputBytes)
+	c.Assert(dec.Decode(s.pdu), IsNil)
+
+	c.Check(int(s.pdu.HeaderLength), Equals, 6)
+	c.Check(int(s.pdu.ApplicationId), Equals, mms.PUSH_APPLICATION_ID)
+	c.Check(s.pdu.ContentType, Equals, mms.VND_WAP_MMS_MESSAGE)
+	c.Check(len(s.pdu.Data), Equals, 102)
+}
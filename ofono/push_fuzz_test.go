@@ -0,0 +1,41 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of mms.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ofono
+
+import "testing"
+
+//FuzzPushPDUDecode feeds arbitrary data to the push decoder, the seed corpus
+//in testdata/fuzz holds the push notifications from the decoding tests.
+func FuzzPushPDUDecode(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pdu := new(PushPDU)
+		if err := NewDecoder(data).Decode(pdu); err != nil {
+			return
+		}
+		if pdu.Data == nil {
+			t.Fatal("push decoded without data")
+		}
+	})
+}
@@ -136,7 +136,7 @@ func (agent *PushAgent) notificationReceived(msg *dbus.Message) (reply *dbus.Mes
 	} else {
 		log.Print("Received ReceiveNotification() method call from ", push.Info["Sender"].Value)
 		log.Print("Push data\n", hex.Dump(push.Data))
-		dec := NewDecoder(push.Data)
+		dec := NewDecoder(push.Data, mms.DefaultDecoderLimits)
 		pdu := new(PushPDU)
 		if err := dec.Decode(pdu); err != nil {
 			log.Print("Error ", err)
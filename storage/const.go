@@ -30,10 +30,13 @@ const (
 	INDETERMINATE = "indeterminate"
 	FORWARDED     = "forwarded"
 	UNREACHABLE   = "unreachable"
+	READ          = "read"
+	DELETED       = "deleted"
 )
 
 const (
 	NOTIFICATION = "notification"
+	PENDING      = "pending"
 	DOWNLOADED   = "downloaded"
 	RECEIVED     = "received"
 	DRAFT        = "draft"
@@ -21,6 +21,8 @@
 
 package storage
 
+import "github.com/ubuntu-phonedations/nuntium/mms"
+
 //SendInfo is a map where every key is a destination and the value can be any of:
 //
 // - "none": no report has been received yet.
@@ -31,6 +33,8 @@ package storage
 // - "indeterminate": cannot determine if the MMS reached its destination.
 // - "forwarded": recipient forwarded the MMS without retrieving it first.
 // - "unreachable": recipient is not reachable.
+// - "read": recipient read the MMS.
+// - "deleted": recipient deleted the MMS without reading it.
 type SendInfo map[string]string
 
 //Status represents an MMS' state
@@ -39,16 +43,20 @@ type SendInfo map[string]string
 //
 // State can be:
 // - "notification": m-Notify.Ind PDU not yet downloaded.
+// - "pending": m-Notify.Ind PDU deferred until the user retrieves it.
 // - "downloaded": m-Retrieve.Conf PDU downloaded, but not yet acknowledged.
 // - "received": m-Retrieve.Conf PDU downloaded and successfully acknowledged.
-// - "draft": m-Send.Req PDU ready for sending.
-// - "sent": m-Send.Req PDU successfully sent.
+// - "draft": m-Send.Req or m-Forward.Req PDU ready for sending.
+// - "sent": m-Send.Req or m-Forward.Req PDU successfully sent.
 //
 // SendState contains the sent state for each delivered message associated to
 // a particular MMS
+//
+// Notification holds the m-Notify.Ind PDU while the message is pending
 type MMSState struct {
 	Id              string
 	State           string
 	ContentLocation string
 	SendState       SendInfo
+	Notification    *mms.MNotificationInd `json:",omitempty"`
 }
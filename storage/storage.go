@@ -24,9 +24,13 @@ package storage
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
+	"github.com/ubuntu-phonedations/nuntium/mms"
 	"launchpad.net/go-xdg/v0"
 )
 
@@ -70,6 +74,30 @@ func CreateResponseFile(uuid string) (*os.File, error) {
 	return os.Create(filePath)
 }
 
+func CreateAcknowledgeFile(uuid string) (*os.File, error) {
+	filePath, err := xdg.Cache.Ensure(path.Join(SUBPATH, uuid+".m-acknowledge.ind"))
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(filePath)
+}
+
+func CreateCancelFile(uuid string) (*os.File, error) {
+	filePath, err := xdg.Cache.Ensure(path.Join(SUBPATH, uuid+".m-cancel.conf"))
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(filePath)
+}
+
+func CreateReadReportFile(uuid string) (*os.File, error) {
+	filePath, err := xdg.Cache.Ensure(path.Join(SUBPATH, uuid+".m-read-rec.ind"))
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(filePath)
+}
+
 func UpdateDownloaded(uuid, filePath string) error {
 	mmsPath, err := xdg.Data.Ensure(path.Join(SUBPATH, uuid+".mms"))
 	if err != nil {
@@ -79,19 +107,105 @@ func UpdateDownloaded(uuid, filePath string) error {
 		//TODO delete file
 		return err
 	}
-	state := MMSState{
-		State: DOWNLOADED,
-	}
+	return updateState(uuid, DOWNLOADED)
+}
+
+func UpdateRetrieved(uuid string) error {
+	return updateState(uuid, RETRIEVED)
+}
+
+//updateState sets the state of the received message identified by uuid, the
+//Content-Location is kept so the message can be forwarded later on.
+func updateState(uuid, newState string) error {
 	storePath, err := xdg.Data.Find(path.Join(SUBPATH, uuid+".db"))
 	if err != nil {
 		return err
 	}
+	state, err := readState(storePath)
+	if err != nil {
+		return err
+	}
+	state.State = newState
+	state.Notification = nil
 	return writeState(state, storePath)
 }
 
-func UpdateRetrieved(uuid string) error {
+//UpdatePending marks the message notified by mNotificationInd as deferred
+//until the user retrieves it and stores the notification to download it from.
+func UpdatePending(mNotificationInd *mms.MNotificationInd) error {
+	storePath, err := xdg.Data.Find(path.Join(SUBPATH, mNotificationInd.UUID+".db"))
+	if err != nil {
+		return err
+	}
+	state, err := readState(storePath)
+	if err != nil {
+		return err
+	}
+	state.State = PENDING
+	state.Notification = mNotificationInd
+	return writeState(state, storePath)
+}
+
+//GetPending returns the notification of the pending message identified by uuid
+func GetPending(uuid string) (*mms.MNotificationInd, error) {
+	storePath, err := xdg.Data.Find(path.Join(SUBPATH, uuid+".db"))
+	if err != nil {
+		return nil, err
+	}
+	state, err := readState(storePath)
+	if err != nil {
+		return nil, err
+	}
+	if state.State != PENDING || state.Notification == nil {
+		return nil, fmt.Errorf("message %s is not pending retrieval", uuid)
+	}
+	return state.Notification, nil
+}
+
+//ListPending returns the notifications of all the pending messages
+func ListPending() ([]*mms.MNotificationInd, error) {
+	storeDir, err := xdg.Data.Ensure(SUBPATH)
+	if err != nil {
+		return nil, err
+	}
+	stores, err := filepath.Glob(filepath.Join(storeDir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+	var pending []*mms.MNotificationInd
+	for _, storePath := range stores {
+		state, err := readState(storePath)
+		if err != nil {
+			continue
+		}
+		if state.State == PENDING && state.Notification != nil {
+			pending = append(pending, state.Notification)
+		}
+	}
+	return pending, nil
+}
+
+//GetContentLocation returns the X-Mms-Content-Location the message identified
+//by uuid was notified with
+func GetContentLocation(uuid string) (string, error) {
+	storePath, err := xdg.Data.Find(path.Join(SUBPATH, uuid+".db"))
+	if err != nil {
+		return "", err
+	}
+	state, err := readState(storePath)
+	if err != nil {
+		return "", err
+	}
+	return state.ContentLocation, nil
+}
+
+//UpdateSent marks the message identified by uuid as sent and stores the
+//Message-ID assigned by the MMSC so delivery reports can be matched later.
+func UpdateSent(uuid, messageId string) error {
 	state := MMSState{
-		State: RETRIEVED,
+		Id:        messageId,
+		State:     SENT,
+		SendState: make(SendInfo),
 	}
 	storePath, err := xdg.Data.Find(path.Join(SUBPATH, uuid+".db"))
 	if err != nil {
@@ -100,6 +214,24 @@ func UpdateRetrieved(uuid string) error {
 	return writeState(state, storePath)
 }
 
+//UpdateSendState sets the delivery state for recipient on the sent message
+//matching messageId and returns the uuid of that message.
+func UpdateSendState(messageId, recipient, sendState string) (string, error) {
+	uuid, state, err := findByMessageId(messageId)
+	if err != nil {
+		return "", err
+	}
+	if state.SendState == nil {
+		state.SendState = make(SendInfo)
+	}
+	state.SendState[recipient] = sendState
+	storePath, err := xdg.Data.Find(path.Join(SUBPATH, uuid+".db"))
+	if err != nil {
+		return "", err
+	}
+	return uuid, writeState(state, storePath)
+}
+
 func CreateSendFile(uuid string) (*os.File, error) {
 	state := MMSState{
 		State: DRAFT,
@@ -119,10 +251,66 @@ func CreateSendFile(uuid string) (*os.File, error) {
 	return os.Create(filePath)
 }
 
+//CreateForwardFile creates the state for a m-forward.req identified by uuid
+//and the file to encode it to
+func CreateForwardFile(uuid string) (*os.File, error) {
+	state := MMSState{
+		State: DRAFT,
+	}
+	storePath, err := xdg.Data.Ensure(path.Join(SUBPATH, uuid+".db"))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeState(state, storePath); err != nil {
+		os.Remove(storePath)
+		return nil, err
+	}
+	filePath, err := xdg.Cache.Ensure(path.Join(SUBPATH, uuid+".m-forward.req"))
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(filePath)
+}
+
 func GetMMS(uuid string) (string, error) {
 	return xdg.Data.Find(path.Join(SUBPATH, uuid+".mms"))
 }
 
+func findByMessageId(messageId string) (string, MMSState, error) {
+	if messageId == "" {
+		return "", MMSState{}, fmt.Errorf("cannot find message with an empty Message-ID")
+	}
+	storeDir, err := xdg.Data.Ensure(SUBPATH)
+	if err != nil {
+		return "", MMSState{}, err
+	}
+	stores, err := filepath.Glob(filepath.Join(storeDir, "*.db"))
+	if err != nil {
+		return "", MMSState{}, err
+	}
+	for _, storePath := range stores {
+		state, err := readState(storePath)
+		if err != nil {
+			continue
+		}
+		if state.State == SENT && state.Id == messageId {
+			return strings.TrimSuffix(filepath.Base(storePath), ".db"), state, nil
+		}
+	}
+	return "", MMSState{}, fmt.Errorf("no sent message with Message-ID %s", messageId)
+}
+
+func readState(storePath string) (state MMSState, err error) {
+	file, err := os.Open(storePath)
+	if err != nil {
+		return state, err
+	}
+	defer file.Close()
+	jsonReader := json.NewDecoder(file)
+	err = jsonReader.Decode(&state)
+	return state, err
+}
+
 func writeState(state MMSState, storePath string) error {
 	file, err := os.Create(storePath)
 	if err != nil {
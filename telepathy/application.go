@@ -0,0 +1,87 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of telepathy.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package telepathy
+
+import (
+	"fmt"
+	"sync"
+)
+
+//applicationRegistry holds the X-Mms-Applic-IDs claimed by clients on the
+//session bus, messages addressed to one of them are delivered to the client
+//that claimed it instead of the messaging UI.
+type applicationRegistry struct {
+	lock   sync.Mutex
+	owners map[string]string
+}
+
+func newApplicationRegistry() *applicationRegistry {
+	return &applicationRegistry{owners: make(map[string]string)}
+}
+
+//claim registers the client with the unique bus name owner as the handler
+//of applicId, claiming it again from the same client is not an error.
+func (registry *applicationRegistry) claim(applicId, owner string) error {
+	if applicId == "" {
+		return fmt.Errorf("empty application id")
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if current, ok := registry.owners[applicId]; ok && current != owner {
+		return fmt.Errorf("application id %s is already registered", applicId)
+	}
+	registry.owners[applicId] = owner
+	return nil
+}
+
+//release drops the claim of owner on applicId
+func (registry *applicationRegistry) release(applicId, owner string) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if current, ok := registry.owners[applicId]; !ok || current != owner {
+		return fmt.Errorf("application id %s is not registered by %s", applicId, owner)
+	}
+	delete(registry.owners, applicId)
+	return nil
+}
+
+//releaseAll drops every claim of owner, it is used when owner leaves the bus.
+func (registry *applicationRegistry) releaseAll(owner string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	for applicId, current := range registry.owners {
+		if current == owner {
+			delete(registry.owners, applicId)
+		}
+	}
+}
+
+//owner returns the unique bus name of the client which claimed applicId
+func (registry *applicationRegistry) owner(applicId string) (string, bool) {
+	if registry == nil || applicId == "" {
+		return "", false
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	owner, ok := registry.owners[applicId]
+	return owner, ok
+}
@@ -29,25 +29,41 @@ const (
 	MMS_MANAGER_DBUS_IFACE = "org.ofono.mms.Manager"
 )
 
+const (
+	DBUS_NAME  = "org.freedesktop.DBus"
+	DBUS_IFACE = "org.freedesktop.DBus"
+)
+
 const (
 	identityProperty           string = "Identity"
 	useDeliveryReportsProperty string = "UseDeliveryReports"
+	deferredDownloadProperty   string = "DeferredDownload"
 	modemObjectPathProperty    string = "ModemObjectPath"
 	messageAddedSignal         string = "MessageAdded"
 	messageRemovedSignal       string = "MessageRemoved"
+	applicationMessageSignal   string = "ApplicationMessageAdded"
+	nameOwnerChangedSignal     string = "NameOwnerChanged"
 	serviceAddedSignal         string = "ServiceAdded"
 	serviceRemovedSignal       string = "ServiceRemoved"
 	preferredContextProperty   string = "PreferredContext"
 	propertyChangedSignal      string = "PropertyChanged"
 	statusProperty             string = "Status"
+	applicIdProperty           string = "ApplicId"
+	replyApplicIdProperty      string = "ReplyApplicId"
+	auxApplicInfoProperty      string = "AuxApplicInfo"
 )
 
 const (
 	PERMANENT_ERROR = "PermanentError"
 	SENT            = "Sent"
 	TRANSIENT_ERROR = "TransientError"
+	DELIVERED       = "Delivered"
+	REJECTED        = "Rejected"
+	EXPIRED         = "Expired"
+	READ            = "Read"
 )
 
 const (
-	PLMN = "/TYPE=PLMN"
+	DRAFT    = "draft"
+	DEFERRED = "deferred"
 )
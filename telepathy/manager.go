@@ -29,9 +29,10 @@ import (
 )
 
 type MMSManager struct {
-	conn     *dbus.Connection
-	msgChan  chan *dbus.Message
-	services []*MMSService
+	conn         *dbus.Connection
+	msgChan      chan *dbus.Message
+	services     []*MMSService
+	applications *applicationRegistry
 }
 
 func NewMMSManager(conn *dbus.Connection) (*MMSManager, error) {
@@ -43,8 +44,18 @@ func NewMMSManager(conn *dbus.Connection) (*MMSManager, error) {
 
 	log.Printf("Registered %s on bus as %s", conn.UniqueName, name.Name)
 
-	manager := MMSManager{conn: conn, msgChan: make(chan *dbus.Message)}
+	nameOwnerWatch, err := conn.WatchSignal(&dbus.MatchRule{
+		Type:      dbus.TypeSignal,
+		Sender:    DBUS_NAME,
+		Interface: DBUS_IFACE,
+		Member:    nameOwnerChangedSignal})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot watch for clients leaving the bus: %s", err)
+	}
+
+	manager := MMSManager{conn: conn, msgChan: make(chan *dbus.Message), applications: newApplicationRegistry()}
 	go manager.watchDBusMethodCalls()
+	go manager.watchNameOwnerChanges(nameOwnerWatch)
 	conn.RegisterObjectPath(MMS_DBUS_PATH, manager.msgChan)
 	return &manager, nil
 }
@@ -57,6 +68,12 @@ func (manager *MMSManager) watchDBusMethodCalls() {
 		case msg.Interface == MMS_MANAGER_DBUS_IFACE && msg.Member == "GetServices":
 			log.Print("Received GetServices()")
 			reply = manager.getServices(msg)
+		case msg.Interface == MMS_MANAGER_DBUS_IFACE && msg.Member == "RegisterApplication":
+			log.Print("Received RegisterApplication()")
+			reply = manager.registerApplication(msg)
+		case msg.Interface == MMS_MANAGER_DBUS_IFACE && msg.Member == "UnregisterApplication":
+			log.Print("Received UnregisterApplication()")
+			reply = manager.unregisterApplication(msg)
 		default:
 			log.Println("Received unkown method call on", msg.Interface, msg.Member)
 			reply = dbus.NewErrorMessage(msg, "org.freedesktop.DBus.Error.UnknownMethod", "Unknown method")
@@ -80,6 +97,51 @@ func (manager *MMSManager) getServices(msg *dbus.Message) *dbus.Message {
 	return reply
 }
 
+//registerApplication claims the X-Mms-Applic-ID passed as argument for the
+//caller, messages carrying it are then signaled to the caller only with
+//ApplicationMessageAdded.
+func (manager *MMSManager) registerApplication(msg *dbus.Message) *dbus.Message {
+	var applicId string
+	if err := msg.Args(&applicId); err != nil {
+		return dbus.NewErrorMessage(msg, "Error.InvalidArguments", "Cannot parse application id")
+	}
+	if err := manager.applications.claim(applicId, msg.Sender); err != nil {
+		log.Print("Cannot register application: ", err)
+		return dbus.NewErrorMessage(msg, "Error.InvalidArguments", err.Error())
+	}
+	log.Printf("Application id %s registered by %s", applicId, msg.Sender)
+	return dbus.NewMethodReturnMessage(msg)
+}
+
+//unregisterApplication releases an X-Mms-Applic-ID claimed by the caller
+func (manager *MMSManager) unregisterApplication(msg *dbus.Message) *dbus.Message {
+	var applicId string
+	if err := msg.Args(&applicId); err != nil {
+		return dbus.NewErrorMessage(msg, "Error.InvalidArguments", "Cannot parse application id")
+	}
+	if err := manager.applications.release(applicId, msg.Sender); err != nil {
+		log.Print("Cannot unregister application: ", err)
+		return dbus.NewErrorMessage(msg, "Error.InvalidArguments", err.Error())
+	}
+	log.Printf("Application id %s unregistered by %s", applicId, msg.Sender)
+	return dbus.NewMethodReturnMessage(msg)
+}
+
+//watchNameOwnerChanges releases the application ids claimed by clients that
+//leave the bus so that their messages go back to the messaging UI.
+func (manager *MMSManager) watchNameOwnerChanges(w *dbus.SignalWatch) {
+	for msg := range w.C {
+		var name, oldOwner, newOwner string
+		if err := msg.Args(&name, &oldOwner, &newOwner); err != nil {
+			log.Print("Cannot parse NameOwnerChanged: ", err)
+			continue
+		}
+		if newOwner == "" && oldOwner != "" {
+			manager.applications.releaseAll(oldOwner)
+		}
+	}
+}
+
 func (manager *MMSManager) serviceAdded(payload *Payload) error {
 	log.Print("Service added ", payload.Path)
 	signal := dbus.NewSignalMessage(MMS_DBUS_PATH, MMS_MANAGER_DBUS_IFACE, serviceAddedSignal)
@@ -92,13 +154,14 @@ func (manager *MMSManager) serviceAdded(payload *Payload) error {
 	return nil
 }
 
-func (manager *MMSManager) AddService(identity string, modemObjPath dbus.ObjectPath, outgoingChannel chan *OutgoingMessage, useDeliveryReports bool) (*MMSService, error) {
+func (manager *MMSManager) AddService(identity string, modemObjPath dbus.ObjectPath, outgoingChannel chan *OutgoingMessage, readChannel, retrieveChannel chan string, forwardChannel chan *ForwardMessage, useDeliveryReports bool) (*MMSService, error) {
 	for i := range manager.services {
 		if manager.services[i].isService(identity) {
 			return manager.services[i], nil
 		}
 	}
-	service := NewMMSService(manager.conn, modemObjPath, identity, outgoingChannel, useDeliveryReports)
+	service := NewMMSService(manager.conn, modemObjPath, identity, outgoingChannel, readChannel, retrieveChannel, forwardChannel, useDeliveryReports)
+	service.applications = manager.applications
 	if err := manager.serviceAdded(&service.payload); err != nil {
 		return &MMSService{}, err
 	}
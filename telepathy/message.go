@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"sync"
 
 	"launchpad.net/go-dbus/v1"
 )
@@ -32,25 +33,34 @@ import (
 var validStatus sort.StringSlice
 
 func init() {
-	validStatus = sort.StringSlice{SENT, PERMANENT_ERROR, TRANSIENT_ERROR}
+	validStatus = sort.StringSlice{SENT, PERMANENT_ERROR, TRANSIENT_ERROR, DELIVERED, REJECTED, EXPIRED, READ}
 	sort.Strings(validStatus)
 }
 
 type MessageInterface struct {
-	conn       *dbus.Connection
-	objectPath dbus.ObjectPath
-	msgChan    chan *dbus.Message
-	deleteChan chan dbus.ObjectPath
-	status     string
+	conn         *dbus.Connection
+	objectPath   dbus.ObjectPath
+	msgChan      chan *dbus.Message
+	deleteChan   chan dbus.ObjectPath
+	markReadChan chan dbus.ObjectPath
+	retrieveChan chan dbus.ObjectPath
+	forwardChan  chan *ForwardMessage
+	statusLock   sync.Mutex
+	status       string
 }
 
-func NewMessageInterface(conn *dbus.Connection, objectPath dbus.ObjectPath, deleteChan chan dbus.ObjectPath) *MessageInterface {
+//NewMessageInterface registers the message interface at objectPath, status
+//is the initial status of the message.
+func NewMessageInterface(conn *dbus.Connection, objectPath dbus.ObjectPath, deleteChan, markReadChan, retrieveChan chan dbus.ObjectPath, forwardChan chan *ForwardMessage, status string) *MessageInterface {
 	msgInterface := MessageInterface{
-		conn:       conn,
-		objectPath: objectPath,
-		deleteChan: deleteChan,
-		msgChan:    make(chan *dbus.Message),
-		status:     "draft",
+		conn:         conn,
+		objectPath:   objectPath,
+		deleteChan:   deleteChan,
+		markReadChan: markReadChan,
+		retrieveChan: retrieveChan,
+		forwardChan:  forwardChan,
+		msgChan:      make(chan *dbus.Message),
+		status:       status,
 	}
 	go msgInterface.watchDBusMethodCalls()
 	conn.RegisterObjectPath(msgInterface.objectPath, msgInterface.msgChan)
@@ -80,6 +90,36 @@ func (msgInterface *MessageInterface) watchDBusMethodCalls() {
 				log.Println("Could not send reply:", err)
 			}
 			msgInterface.deleteChan <- msgInterface.objectPath
+		case "MarkRead":
+			reply = dbus.NewMethodReturnMessage(msg)
+			if err := msgInterface.conn.Send(reply); err != nil {
+				log.Println("Could not send reply:", err)
+			}
+			msgInterface.markReadChan <- msgInterface.objectPath
+		case "Retrieve":
+			if msgInterface.getStatus() != DEFERRED {
+				reply = dbus.NewErrorMessage(msg, "Error.NotAllowed", "Message is not pending retrieval")
+				if err := msgInterface.conn.Send(reply); err != nil {
+					log.Println("Could not send reply:", err)
+				}
+				continue
+			}
+			reply = dbus.NewMethodReturnMessage(msg)
+			if err := msgInterface.conn.Send(reply); err != nil {
+				log.Println("Could not send reply:", err)
+			}
+			msgInterface.retrieveChan <- msgInterface.objectPath
+		case "Forward":
+			forwardMessage, err := msgInterface.parseForward(msg)
+			if err != nil {
+				log.Print("Cannot parse Forward arguments: ", err)
+				reply = dbus.NewErrorMessage(msg, "Error.InvalidArguments", "Cannot parse recipients")
+				if err := msgInterface.conn.Send(reply); err != nil {
+					log.Println("Could not send reply:", err)
+				}
+				continue
+			}
+			msgInterface.forwardChan <- forwardMessage
 		default:
 			log.Println("Received unkown method call on", msg.Interface, msg.Member)
 			reply = dbus.NewErrorMessage(msg, "org.freedesktop.DBus.Error.UnknownMethod", "Unknown method")
@@ -90,10 +130,30 @@ func (msgInterface *MessageInterface) watchDBusMethodCalls() {
 	}
 }
 
+//parseForward reads the recipients to forward the message to, the reply is
+//sent once the forwarded message has been created
+func (msgInterface *MessageInterface) parseForward(msg *dbus.Message) (*ForwardMessage, error) {
+	uuid, err := getUUIDFromObjectPath(msgInterface.objectPath)
+	if err != nil {
+		return nil, err
+	}
+	forwardMessage := ForwardMessage{UUID: uuid, call: msg}
+	if err := msg.Args(&forwardMessage.Recipients); err != nil {
+		return nil, err
+	}
+	if len(forwardMessage.Recipients) == 0 {
+		return nil, fmt.Errorf("no recipients to forward %s to", uuid)
+	}
+	forwardMessage.Reply = dbus.NewMethodReturnMessage(msg)
+	return &forwardMessage, nil
+}
+
 func (msgInterface *MessageInterface) StatusChanged(status string) error {
 	i := validStatus.Search(status)
 	if i < validStatus.Len() && validStatus[i] == status {
+		msgInterface.statusLock.Lock()
 		msgInterface.status = status
+		msgInterface.statusLock.Unlock()
 		signal := dbus.NewSignalMessage(msgInterface.objectPath, MMS_MESSAGE_DBUS_IFACE, propertyChangedSignal)
 		if err := signal.AppendArgs(statusProperty, dbus.Variant{status}); err != nil {
 			return err
@@ -107,9 +167,15 @@ func (msgInterface *MessageInterface) StatusChanged(status string) error {
 	return fmt.Errorf("status %s is not a valid status", status)
 }
 
+func (msgInterface *MessageInterface) getStatus() string {
+	msgInterface.statusLock.Lock()
+	defer msgInterface.statusLock.Unlock()
+	return msgInterface.status
+}
+
 func (msgInterface *MessageInterface) GetPayload() *Payload {
 	properties := make(map[string]dbus.Variant)
-	properties["Status"] = dbus.Variant{msgInterface.status}
+	properties["Status"] = dbus.Variant{msgInterface.getStatus()}
 	return &Payload{
 		Path:       msgInterface.objectPath,
 		Properties: properties,
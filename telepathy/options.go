@@ -0,0 +1,152 @@
+/*
+ * Copyright 2014 Canonical Ltd.
+ *
+ * Authors:
+ * Sergio Schvezov: sergio.schvezov@cannical.com
+ *
+ * This file is part of telepathy.
+ *
+ * mms is free software; you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation; version 3.
+ *
+ * mms is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package telepathy
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ubuntu-phonedations/nuntium/mms"
+	"launchpad.net/go-dbus/v1"
+)
+
+//SendOptions holds the per message options given to SendMessage, the byte
+//fields hold the mms encoded value and are left as 0 when not set.
+type SendOptions struct {
+	Subject          string
+	DeliveryReport   bool
+	ReadReport       bool
+	Priority         byte
+	Class            byte
+	Expiry           uint64
+	SenderVisibility byte
+	Cc, Bcc          []string
+}
+
+var priorityOptions = map[string]byte{
+	"low":    mms.PriorityLow,
+	"normal": mms.PriorityNormal,
+	"high":   mms.PriorityHigh,
+}
+
+var classOptions = map[string]byte{
+	"personal":      mms.ClassPersonal,
+	"advertisement": mms.ClassAdvertisement,
+	"informational": mms.ClassInformational,
+	"auto":          mms.ClassAuto,
+}
+
+var senderVisibilityOptions = map[string]byte{
+	"hide": mms.SenderVisibilityHide,
+	"show": mms.SenderVisibilityShow,
+}
+
+//parseSendOptions validates the options dictionary from SendMessage,
+//useDeliveryReports is used when no DeliveryReport option is given.
+func parseSendOptions(options map[string]dbus.Variant, useDeliveryReports bool) (SendOptions, error) {
+	sendOptions := SendOptions{DeliveryReport: useDeliveryReports}
+	var err error
+	for name, variant := range options {
+		switch name {
+		case "Subject":
+			sendOptions.Subject, err = variantString(name, variant)
+		case "DeliveryReport":
+			sendOptions.DeliveryReport, err = variantBool(name, variant)
+		case "ReadReport":
+			sendOptions.ReadReport, err = variantBool(name, variant)
+		case "Priority":
+			sendOptions.Priority, err = variantChoice(name, variant, priorityOptions)
+		case "Class":
+			sendOptions.Class, err = variantChoice(name, variant, classOptions)
+		case "Expiry":
+			sendOptions.Expiry, err = variantUint(name, variant)
+		case "SenderVisibility":
+			sendOptions.SenderVisibility, err = variantChoice(name, variant, senderVisibilityOptions)
+		case "Cc":
+			sendOptions.Cc, err = variantStrings(name, variant)
+		case "Bcc":
+			sendOptions.Bcc, err = variantStrings(name, variant)
+		default:
+			err = fmt.Errorf("unknown option %s", name)
+		}
+		if err != nil {
+			return SendOptions{}, err
+		}
+	}
+	return sendOptions, nil
+}
+
+func variantString(name string, variant dbus.Variant) (string, error) {
+	if v, ok := variant.Value.(string); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("option %s must be a string", name)
+}
+
+func variantBool(name string, variant dbus.Variant) (bool, error) {
+	if v, ok := variant.Value.(bool); ok {
+		return v, nil
+	}
+	return false, fmt.Errorf("option %s must be a boolean", name)
+}
+
+func variantUint(name string, variant dbus.Variant) (uint64, error) {
+	v := reflect.ValueOf(variant.Value)
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() >= 0 {
+			return uint64(v.Int()), nil
+		}
+	}
+	return 0, fmt.Errorf("option %s must be a positive integer", name)
+}
+
+func variantStrings(name string, variant dbus.Variant) ([]string, error) {
+	switch v := variant.Value.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i := range v {
+			str, ok := v[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("option %s must be a list of strings", name)
+			}
+			strs[i] = str
+		}
+		return strs, nil
+	}
+	return nil, fmt.Errorf("option %s must be a list of strings", name)
+}
+
+func variantChoice(name string, variant dbus.Variant, choices map[string]byte) (byte, error) {
+	str, err := variantString(name, variant)
+	if err != nil {
+		return 0, err
+	}
+	if v, ok := choices[str]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("%s is not a valid value for option %s", str, name)
+}
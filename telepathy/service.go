@@ -27,7 +27,7 @@ import (
 	"log"
 	"path/filepath"
 	"reflect"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/ubuntu-phonedations/nuntium/mms"
@@ -44,13 +44,20 @@ type Payload struct {
 
 type MMSService struct {
 	payload         Payload
+	propertiesLock  sync.Mutex
 	Properties      map[string]dbus.Variant
 	conn            *dbus.Connection
 	msgChan         chan *dbus.Message
 	messageHandlers map[dbus.ObjectPath]*MessageInterface
 	msgDeleteChan   chan dbus.ObjectPath
+	msgMarkReadChan chan dbus.ObjectPath
+	msgRetrieveChan chan dbus.ObjectPath
 	identity        string
 	outMessage      chan *OutgoingMessage
+	readMessage     chan string
+	retrieveMessage chan string
+	forwardMessage  chan *ForwardMessage
+	applications    *applicationRegistry
 }
 
 type Attachment struct {
@@ -61,6 +68,13 @@ type Attachment struct {
 	Length    uint64
 }
 
+//Slide is a simplified SMIL slide, Attachments holds the Id of the attachments
+//presented in the slide and Duration is in milliseconds.
+type Slide struct {
+	Duration    uint64
+	Attachments []string
+}
+
 type OutAttachment struct {
 	Id          string
 	ContentType string
@@ -70,14 +84,27 @@ type OutAttachment struct {
 type OutgoingMessage struct {
 	Recipients  []string
 	Attachments []OutAttachment
+	Options     SendOptions
 	Reply       *dbus.Message
+	call        *dbus.Message
 }
 
-func NewMMSService(conn *dbus.Connection, modemObjPath dbus.ObjectPath, identity string, outgoingChannel chan *OutgoingMessage, useDeliveryReports bool) *MMSService {
+//ForwardMessage is a request to forward the message identified by UUID to
+//Recipients, Reply is sent with the path of the forwarded message or else an
+//error is sent with ReplyForwardError.
+type ForwardMessage struct {
+	UUID       string
+	Recipients []string
+	Reply      *dbus.Message
+	call       *dbus.Message
+}
+
+func NewMMSService(conn *dbus.Connection, modemObjPath dbus.ObjectPath, identity string, outgoingChannel chan *OutgoingMessage, readChannel, retrieveChannel chan string, forwardChannel chan *ForwardMessage, useDeliveryReports bool) *MMSService {
 	properties := make(map[string]dbus.Variant)
 	properties[identityProperty] = dbus.Variant{identity}
 	serviceProperties := make(map[string]dbus.Variant)
 	serviceProperties[useDeliveryReportsProperty] = dbus.Variant{useDeliveryReports}
+	serviceProperties[deferredDownloadProperty] = dbus.Variant{false}
 	serviceProperties[modemObjectPathProperty] = dbus.Variant{modemObjPath}
 	payload := Payload{
 		Path:       dbus.ObjectPath(MMS_DBUS_PATH + "/" + identity),
@@ -89,12 +116,19 @@ func NewMMSService(conn *dbus.Connection, modemObjPath dbus.ObjectPath, identity
 		conn:            conn,
 		msgChan:         make(chan *dbus.Message),
 		msgDeleteChan:   make(chan dbus.ObjectPath),
+		msgMarkReadChan: make(chan dbus.ObjectPath),
+		msgRetrieveChan: make(chan dbus.ObjectPath),
 		messageHandlers: make(map[dbus.ObjectPath]*MessageInterface),
 		outMessage:      outgoingChannel,
+		readMessage:     readChannel,
+		retrieveMessage: retrieveChannel,
+		forwardMessage:  forwardChannel,
 		identity:        identity,
 	}
 	go service.watchDBusMethodCalls()
 	go service.watchMessageDeleteCalls()
+	go service.watchMessageMarkReadCalls()
+	go service.watchMessageRetrieveCalls()
 	conn.RegisterObjectPath(payload.Path, service.msgChan)
 	return &service
 }
@@ -107,6 +141,28 @@ func (service *MMSService) watchMessageDeleteCalls() {
 	}
 }
 
+func (service *MMSService) watchMessageMarkReadCalls() {
+	for msgObjectPath := range service.msgMarkReadChan {
+		uuid, err := getUUIDFromObjectPath(msgObjectPath)
+		if err != nil {
+			log.Print("Failed to mark ", msgObjectPath, " as read: ", err)
+			continue
+		}
+		service.readMessage <- uuid
+	}
+}
+
+func (service *MMSService) watchMessageRetrieveCalls() {
+	for msgObjectPath := range service.msgRetrieveChan {
+		uuid, err := getUUIDFromObjectPath(msgObjectPath)
+		if err != nil {
+			log.Print("Failed to retrieve ", msgObjectPath, ": ", err)
+			continue
+		}
+		service.retrieveMessage <- uuid
+	}
+}
+
 func (service *MMSService) watchDBusMethodCalls() {
 	for msg := range service.msgChan {
 		var reply *dbus.Message
@@ -133,12 +189,12 @@ func (service *MMSService) watchDBusMethodCalls() {
 		case "GetProperties":
 			reply = dbus.NewMethodReturnMessage(msg)
 			if pc, err := service.GetPreferredContext(); err == nil {
-				service.Properties[preferredContextProperty] = dbus.Variant{pc}
+				service.setPropertyValue(preferredContextProperty, pc)
 			} else {
 				// Using "/" as an invalid 'path' even though it could be considered 'incorrect'
-				service.Properties[preferredContextProperty] = dbus.Variant{dbus.ObjectPath("/")}
+				service.setPropertyValue(preferredContextProperty, dbus.ObjectPath("/"))
 			}
-			if err := reply.AppendArgs(service.Properties); err != nil {
+			if err := reply.AppendArgs(service.getProperties()); err != nil {
 				log.Print("Cannot parse payload data from services")
 				reply = dbus.NewErrorMessage(msg, "Error.InvalidArguments", "Cannot parse services")
 			}
@@ -156,16 +212,15 @@ func (service *MMSService) watchDBusMethodCalls() {
 				log.Println("Could not send reply:", err)
 			}
 		case "SendMessage":
-			var outMessage OutgoingMessage
-			outMessage.Reply = dbus.NewMethodReturnMessage(msg)
-			if err := msg.Args(&outMessage.Recipients, &outMessage.Attachments); err != nil {
-				log.Print("Cannot parse payload data from services")
+			outMessage, err := service.parseSendMessage(msg)
+			if err != nil {
+				log.Print("Cannot parse payload data from services: ", err)
 				reply = dbus.NewErrorMessage(msg, "Error.InvalidArguments", "Cannot parse New Message")
 				if err := service.conn.Send(reply); err != nil {
 					log.Println("Could not send reply:", err)
 				}
 			} else {
-				service.outMessage <- &outMessage
+				service.outMessage <- outMessage
 			}
 		default:
 			log.Println("Received unkown method call on", msg.Interface, msg.Member)
@@ -180,6 +235,29 @@ func (service *MMSService) watchDBusMethodCalls() {
 	}
 }
 
+//parseSendMessage reads the arguments for SendMessage, the options
+//dictionary is optional to remain compatible with clients that only send
+//recipients and attachments.
+func (service *MMSService) parseSendMessage(msg *dbus.Message) (*OutgoingMessage, error) {
+	var outMessage OutgoingMessage
+	var options map[string]dbus.Variant
+	if err := msg.Args(&outMessage.Recipients, &outMessage.Attachments, &options); err != nil {
+		options = nil
+		if err := msg.Args(&outMessage.Recipients, &outMessage.Attachments); err != nil {
+			return nil, err
+		}
+	}
+	useDeliveryReports, _ := service.getPropertyValue(useDeliveryReportsProperty).(bool)
+	sendOptions, err := parseSendOptions(options, useDeliveryReports)
+	if err != nil {
+		return nil, err
+	}
+	outMessage.Options = sendOptions
+	outMessage.Reply = dbus.NewMethodReturnMessage(msg)
+	outMessage.call = msg
+	return &outMessage, nil
+}
+
 func getUUIDFromObjectPath(objectPath dbus.ObjectPath) (string, error) {
 	str := string(objectPath)
 	defaultError := fmt.Errorf("%s is not a proper object path for a Message", str)
@@ -213,6 +291,40 @@ func (service *MMSService) GetPreferredContext() (dbus.ObjectPath, error) {
 	return storage.GetPreferredContext(service.identity)
 }
 
+//IsDeferredDownload tells if incoming messages should wait for the client to
+//call Retrieve on them instead of being downloaded as soon as they are
+//notified.
+func (service *MMSService) IsDeferredDownload() bool {
+	deferredDownload, _ := service.getPropertyValue(deferredDownloadProperty).(bool)
+	return deferredDownload
+}
+
+//getPropertyValue returns the value of the service property name, properties
+//are read from the mediator while D-Bus calls update them.
+func (service *MMSService) getPropertyValue(name string) interface{} {
+	service.propertiesLock.Lock()
+	defer service.propertiesLock.Unlock()
+	return service.Properties[name].Value
+}
+
+func (service *MMSService) setPropertyValue(name string, value interface{}) {
+	service.propertiesLock.Lock()
+	defer service.propertiesLock.Unlock()
+	service.Properties[name] = dbus.Variant{value}
+}
+
+//getProperties returns a copy of the service properties to reply to
+//GetProperties with
+func (service *MMSService) getProperties() map[string]dbus.Variant {
+	service.propertiesLock.Lock()
+	defer service.propertiesLock.Unlock()
+	properties := make(map[string]dbus.Variant, len(service.Properties))
+	for k, v := range service.Properties {
+		properties[k] = v
+	}
+	return properties
+}
+
 func (service *MMSService) setProperty(msg *dbus.Message) error {
 	var propertyName string
 	var propertyValue dbus.Variant
@@ -223,8 +335,19 @@ func (service *MMSService) setProperty(msg *dbus.Message) error {
 	switch propertyName {
 	case preferredContextProperty:
 		preferredContextObjectPath := dbus.ObjectPath(reflect.ValueOf(propertyValue.Value).String())
-		service.Properties[preferredContextProperty] = dbus.Variant{preferredContextObjectPath}
+		service.setPropertyValue(preferredContextProperty, preferredContextObjectPath)
 		return service.SetPreferredContext(preferredContextObjectPath)
+	case deferredDownloadProperty:
+		deferredDownload, ok := propertyValue.Value.(bool)
+		if !ok {
+			return errors.New("property value must be a boolean")
+		}
+		service.setPropertyValue(deferredDownloadProperty, deferredDownload)
+		signal := dbus.NewSignalMessage(service.payload.Path, MMS_SERVICE_DBUS_IFACE, propertyChangedSignal)
+		if err := signal.AppendArgs(deferredDownloadProperty, dbus.Variant{deferredDownload}); err != nil {
+			return err
+		}
+		return service.conn.Send(signal)
 	default:
 		errors.New("property cannot be set")
 	}
@@ -235,8 +358,10 @@ func (service *MMSService) setProperty(msg *dbus.Message) error {
 //message.
 //It also actually removes the message from storage.
 func (service *MMSService) MessageRemoved(objectPath dbus.ObjectPath) error {
-	service.messageHandlers[objectPath].Close()
-	delete(service.messageHandlers, objectPath)
+	if msgInterface, ok := service.messageHandlers[objectPath]; ok {
+		msgInterface.Close()
+		delete(service.messageHandlers, objectPath)
+	}
 
 	uuid, err := getUUIDFromObjectPath(objectPath)
 	if err != nil {
@@ -256,6 +381,12 @@ func (service *MMSService) MessageRemoved(objectPath dbus.ObjectPath) error {
 	return nil
 }
 
+//MessageCancelled removes the message identified by uuid, which the message
+//center cancelled before it was downloaded, and emits MessageRemoved for it.
+func (service *MMSService) MessageCancelled(uuid string) error {
+	return service.MessageRemoved(service.genMessagePath(uuid))
+}
+
 //IncomingMessageAdded emits a MessageAdded with the path to the added message which
 //is taken as a parameter and creates an object path on the message interface.
 func (service *MMSService) IncomingMessageAdded(mRetConf *mms.MRetrieveConf) error {
@@ -263,7 +394,76 @@ func (service *MMSService) IncomingMessageAdded(mRetConf *mms.MRetrieveConf) err
 	if err != nil {
 		return err
 	}
-	service.messageHandlers[payload.Path] = NewMessageInterface(service.conn, payload.Path, service.msgDeleteChan)
+	if msgInterface, ok := service.messageHandlers[payload.Path]; ok {
+		// replaces the interface of a previously deferred message
+		msgInterface.Close()
+	}
+	service.messageHandlers[payload.Path] = NewMessageInterface(service.conn, payload.Path, service.msgDeleteChan, service.msgMarkReadChan, service.msgRetrieveChan, service.forwardMessage, DRAFT)
+	return service.MessageAdded(&payload)
+}
+
+//IsApplicationRegistered tells if a client claimed applicId with
+//RegisterApplication.
+func (service *MMSService) IsApplicationRegistered(applicId string) bool {
+	_, ok := service.applications.owner(applicId)
+	return ok
+}
+
+//ApplicationMessageAdded emits an ApplicationMessageAdded to the client that
+//registered the X-Mms-Applic-ID of mRetConf instead of a MessageAdded, it
+//returns false if no client claims the id so the message can be announced as
+//any other.
+func (service *MMSService) ApplicationMessageAdded(mRetConf *mms.MRetrieveConf) (bool, error) {
+	owner, ok := service.applications.owner(mRetConf.ApplicId)
+	if !ok {
+		return false, nil
+	}
+	payload, err := service.parseMessage(mRetConf)
+	if err != nil {
+		return false, err
+	}
+	payload.Properties[applicIdProperty] = dbus.Variant{mRetConf.ApplicId}
+	if mRetConf.ReplyApplicId != "" {
+		payload.Properties[replyApplicIdProperty] = dbus.Variant{mRetConf.ReplyApplicId}
+	}
+	if mRetConf.AuxApplicInfo != "" {
+		payload.Properties[auxApplicInfoProperty] = dbus.Variant{mRetConf.AuxApplicInfo}
+	}
+
+	signal := dbus.NewSignalMessage(service.payload.Path, MMS_SERVICE_DBUS_IFACE, applicationMessageSignal)
+	signal.Dest = owner
+	if err := signal.AppendArgs(payload.Path, payload.Properties); err != nil {
+		return false, err
+	}
+	if msgInterface, ok := service.messageHandlers[payload.Path]; ok {
+		msgInterface.Close()
+	}
+	service.messageHandlers[payload.Path] = NewMessageInterface(service.conn, payload.Path, service.msgDeleteChan, service.msgMarkReadChan, service.msgRetrieveChan, service.forwardMessage, DRAFT)
+	if err := service.conn.Send(signal); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//DeferredMessageAdded emits a MessageAdded with status "deferred" for a
+//message that has been notified but not yet downloaded, the message can
+//then be downloaded by calling Retrieve on its message interface.
+func (service *MMSService) DeferredMessageAdded(mNotificationInd *mms.MNotificationInd) error {
+	params := make(map[string]dbus.Variant)
+	params["Status"] = dbus.Variant{DEFERRED}
+	if sender, err := mms.ParseAddress(mNotificationInd.From); err == nil {
+		params["Sender"] = dbus.Variant{sender.Value}
+	}
+	if mNotificationInd.Subject != "" {
+		params["Subject"] = dbus.Variant{mNotificationInd.Subject}
+	}
+	params["Size"] = dbus.Variant{mNotificationInd.Size}
+	if mNotificationInd.Expiry != 0 {
+		params["Expiry"] = dbus.Variant{parseDate(mNotificationInd.Expiry)}
+	}
+	payload := Payload{Path: service.genMessagePath(mNotificationInd.UUID), Properties: params}
+	msgInterface := NewMessageInterface(service.conn, payload.Path, service.msgDeleteChan, service.msgMarkReadChan, service.msgRetrieveChan, service.forwardMessage, DEFERRED)
+	service.messageHandlers[payload.Path] = msgInterface
 	return service.MessageAdded(&payload)
 }
 
@@ -289,6 +489,8 @@ func (service *MMSService) Close() {
 	service.conn.UnregisterObjectPath(service.payload.Path)
 	close(service.msgChan)
 	close(service.msgDeleteChan)
+	close(service.msgMarkReadChan)
+	close(service.msgRetrieveChan)
 }
 
 func (service *MMSService) parseMessage(mRetConf *mms.MRetrieveConf) (Payload, error) {
@@ -300,12 +502,17 @@ func (service *MMSService) parseMessage(mRetConf *mms.MRetrieveConf) (Payload, e
 	if mRetConf.Subject != "" {
 		params["Subject"] = dbus.Variant{mRetConf.Subject}
 	}
-	sender := mRetConf.From
-	if strings.HasSuffix(mRetConf.From, PLMN) {
-		params["Sender"] = dbus.Variant{sender[:len(sender)-len(PLMN)]}
+	if sender, err := mms.ParseAddress(mRetConf.From); err == nil {
+		params["Sender"] = dbus.Variant{sender.Value}
 	}
 
-	params["Recipients"] = dbus.Variant{parseRecipients(strings.Join(mRetConf.To, ","))}
+	params["Recipients"] = dbus.Variant{parseRecipients(mRetConf.To)}
+	if len(mRetConf.Cc) > 0 {
+		params["Cc"] = dbus.Variant{parseRecipients(mRetConf.Cc)}
+	}
+	if len(mRetConf.Bcc) > 0 {
+		params["Bcc"] = dbus.Variant{parseRecipients(mRetConf.Bcc)}
+	}
 	if smil, err := mRetConf.GetSmil(); err == nil {
 		params["Smil"] = dbus.Variant{smil}
 	}
@@ -328,21 +535,43 @@ func (service *MMSService) parseMessage(mRetConf *mms.MRetrieveConf) (Payload, e
 		attachments = append(attachments, attachment)
 	}
 	params["Attachments"] = dbus.Variant{attachments}
+	if smil, err := mRetConf.GetSmilSlides(); err == nil {
+		params["Slides"] = dbus.Variant{parseSlides(smil)}
+	} else {
+		log.Print("Cannot parse SMIL slides: ", err)
+	}
 	payload := Payload{Path: service.genMessagePath(mRetConf.UUID), Properties: params}
 	return payload, nil
 }
 
+func parseSlides(smil *mms.Smil) []Slide {
+	slides := make([]Slide, 0, len(smil.Slides))
+	for _, s := range smil.Slides {
+		slide := Slide{Duration: uint64(s.Duration / time.Millisecond)}
+		for _, m := range s.Media {
+			if m.Attachment != nil {
+				slide.Attachments = append(slide.Attachments, m.Attachment.ContentId)
+			}
+		}
+		slides = append(slides, slide)
+	}
+	return slides
+}
+
 func parseDate(unixTime uint64) string {
 	const layout = "2014-03-30T18:15:30-0300"
 	date := time.Unix(int64(unixTime), 0)
 	return date.Format(time.RFC3339)
 }
 
-func parseRecipients(to string) []string {
-	recipients := strings.Split(to, ",")
-	for i := range recipients {
-		if strings.HasSuffix(recipients[i], PLMN) {
-			recipients[i] = recipients[i][:len(recipients[i])-len(PLMN)]
+//parseRecipients returns the addresses without the type suffix used in PDUs,
+//addresses are not joined as e-mail display names may contain commas
+func parseRecipients(addresses []string) []string {
+	recipients := make([]string, len(addresses))
+	for i := range addresses {
+		recipients[i] = addresses[i]
+		if address, err := mms.ParseAddress(addresses[i]); err == nil {
+			recipients[i] = address.Value
 		}
 	}
 	return recipients
@@ -365,18 +594,50 @@ func (service *MMSService) MessageStatusChanged(uuid, status string) error {
 	return fmt.Errorf("no message interface handler for object path %s", msgObjectPath)
 }
 
-func (service *MMSService) ReplySendMessage(reply *dbus.Message, uuid string) (dbus.ObjectPath, error) {
+//DecodeErrorStatus maps an error decoding a response from the message center
+//to a message status, incomplete data may be fixed by trying again while any
+//other decoding failure will happen again.
+func DecodeErrorStatus(err error) string {
+	if errors.Is(err, mms.ErrTruncated) {
+		return TRANSIENT_ERROR
+	}
+	var decodeErr *mms.DecodeError
+	if errors.As(err, &decodeErr) {
+		return PERMANENT_ERROR
+	}
+	return TRANSIENT_ERROR
+}
+
+//ReplySendMessage replies to SendMessage or Forward with the path of the
+//message identified by uuid and announces it with its encoded recipients
+func (service *MMSService) ReplySendMessage(reply *dbus.Message, uuid string, recipients []string) (dbus.ObjectPath, error) {
 	msgObjectPath := service.genMessagePath(uuid)
 	reply.AppendArgs(msgObjectPath)
 	if err := service.conn.Send(reply); err != nil {
 		return "", err
 	}
-	msg := NewMessageInterface(service.conn, msgObjectPath, service.msgDeleteChan)
+	msg := NewMessageInterface(service.conn, msgObjectPath, service.msgDeleteChan, service.msgMarkReadChan, service.msgRetrieveChan, service.forwardMessage, DRAFT)
 	service.messageHandlers[msgObjectPath] = msg
-	service.MessageAdded(msg.GetPayload())
+	payload := msg.GetPayload()
+	payload.Properties["Recipients"] = dbus.Variant{parseRecipients(recipients)}
+	service.MessageAdded(payload)
 	return msgObjectPath, nil
 }
 
+//ReplySendMessageError replies to a SendMessage that cannot be carried out
+//with the D-Bus error name and message
+func (service *MMSService) ReplySendMessageError(outMessage *OutgoingMessage, name, message string) error {
+	reply := dbus.NewErrorMessage(outMessage.call, name, message)
+	return service.conn.Send(reply)
+}
+
+//ReplyForwardError replies to a Forward that cannot be carried out with the
+//D-Bus error name and message
+func (service *MMSService) ReplyForwardError(forwardMessage *ForwardMessage, name, message string) error {
+	reply := dbus.NewErrorMessage(forwardMessage.call, name, message)
+	return service.conn.Send(reply)
+}
+
 //TODO randomly creating a uuid until the download manager does this for us
 func (service *MMSService) genMessagePath(uuid string) dbus.ObjectPath {
 	return dbus.ObjectPath(MMS_DBUS_PATH + "/" + service.identity + "/" + uuid)